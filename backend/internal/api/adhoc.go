@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/runner"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/google/uuid"
+)
+
+// runAdHoc runs an ansible module against hosts from a job runner, with
+// each host's ansible_host, vars and SSH cert as in a playbook run. It
+// returns the callback plugin's result for each inventory host name that
+// reported one, and the rest of the output for when something went wrong
+// before Ansible reached the hosts.
+func runAdHoc(ctx context.Context, servers *store.ServerStore, sshCerts *store.SSHCertStore, server *models.Server, hosts []*models.Host, module, args string) (map[string]*runner.Event, string, error) {
+	rn, err := runnerForServer(servers, server)
+	if err != nil {
+		return nil, "", err
+	}
+	src, err := runner.AdHocSource()
+	if err != nil {
+		return nil, "", err
+	}
+	spec := &runner.RunSpec{
+		// A plain UUID: the Kubernetes runner names its objects after the
+		// start of the run ID.
+		RunID:      uuid.New().String(),
+		Source:     src,
+		Inventory:  buildGroupInventory(hosts),
+		HostCerts:  hostCertsFor(sshCerts, hosts),
+		PreCommand: server.PreCommand,
+		AdHoc:      &runner.AdHoc{Module: module, Args: args},
+	}
+
+	results := map[string]*runner.Event{}
+	var output strings.Builder
+	outputCh := make(chan string, 256)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for line := range outputCh {
+			if ev, rest, ok := runner.ParseEvent(line); ok {
+				if ev.Event == runner.EventHostResult {
+					results[ev.Host] = ev
+				}
+				line = rest
+			}
+			if line != "" {
+				output.WriteString(line + "\n")
+			}
+		}
+	}()
+	result := rn.Run(ctx, spec, outputCh)
+	close(outputCh)
+	<-done
+
+	// A non-zero exit only means some host failed, which its result says.
+	if result.Err != nil {
+		return results, output.String(), fmt.Errorf("%s: %w", module, result.Err)
+	}
+	return results, output.String(), nil
+}
+
+// adHocFailure describes why a host has no usable result from runAdHoc: its
+// failed or unreachable result, or the tail of the output when Ansible never
+// reported on it.
+func adHocFailure(ev *runner.Event, output string) string {
+	if ev != nil {
+		if ev.Msg != "" {
+			return ev.Status + ": " + ev.Msg
+		}
+		return ev.Status
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	if tail := strings.TrimSpace(strings.Join(lines, "\n")); tail != "" {
+		return "no result from Ansible: " + tail
+	}
+	return "no result from Ansible"
+}
@@ -0,0 +1,393 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// memberHost recovers the host name from a server-group member run's
+// inventory, which names that one host.
+func memberHost(inventory string) string {
+	if names := inventoryHostNames(inventory); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+var errBatchSize = errors.New(`rollout batch_size must be a count like "5" or a percentage like "25%"`)
+
+// parseBatchSize reads a rollout batch size: a positive count, or a
+// percentage from 1 to 100 when pct is set. "" gives 0 (all members at once).
+func parseBatchSize(s string) (n int, pct bool, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		pct = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	}
+	n, err = strconv.Atoi(s)
+	if err != nil || n < 1 || (pct && n > 100) {
+		return 0, false, errBatchSize
+	}
+	return n, pct, nil
+}
+
+func validateRollout(r models.Rollout) error {
+	if _, _, err := parseBatchSize(r.BatchSize); err != nil {
+		return err
+	}
+	if r.PauseSeconds < 0 {
+		return errors.New("rollout pause_seconds must be 0 or more")
+	}
+	if r.MaxFailPercent != nil && (*r.MaxFailPercent < 0 || *r.MaxFailPercent > 100) {
+		return errors.New("rollout max_fail_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// rolloutBatchSize is how many of members run per rollout batch. Percentages
+// round up so every batch has at least one member.
+func rolloutBatchSize(r models.Rollout, members int) int {
+	n, pct, err := parseBatchSize(r.BatchSize)
+	if err != nil || n == 0 {
+		return members
+	}
+	if pct {
+		n = (members*n + 99) / 100
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > members {
+		n = members
+	}
+	return n
+}
+
+// ── Rollout ───────────────────────────────────────────────────────────────────
+
+// advanceBatch moves a batch's rollout on once its current rollout batch has
+// finished: it stops the rollout when too many runs have failed, finishes it
+// after the last batch, or starts the next batch, after a pause or approval
+// when the rollout asks for one. A cancelling batch is finished once its last
+// run has stopped. It is called whenever a member run finishes.
+func (h *RunsHandler) advanceBatch(batchID string) {
+	h.batchMu.Lock()
+	defer h.batchMu.Unlock()
+
+	for {
+		b, err := h.batches.Get(batchID)
+		if err != nil {
+			log.Printf("[batches] load batch %s: %v", batchID, err)
+			return
+		}
+		if b == nil || b.FinishedAt != nil || b.Status == "awaiting_approval" {
+			return
+		}
+		runs, err := h.runs.ListBatch(batchID)
+		if err != nil {
+			log.Printf("[batches] load runs of batch %s: %v", batchID, err)
+			return
+		}
+		if b.Status == "cancelling" {
+			for _, r := range runs {
+				if r.Status == "pending" || r.Status == "running" {
+					return
+				}
+			}
+			h.finishBatch(b, "cancelled", b.Message)
+			return
+		}
+		// Cancelled runs count neither way towards the failure percentage.
+		var finished, failed int
+		for _, r := range runs {
+			if r.BatchPhase > b.Phase {
+				continue
+			}
+			switch r.Status {
+			case "pending", "running":
+				return // the current batch is still going
+			case "cancelled":
+				continue
+			case "failed", "timed_out":
+				failed++
+			}
+			finished++
+		}
+
+		if b.Status == "paused" {
+			if b.NextPhaseAt != nil {
+				if wait := time.Until(*b.NextPhaseAt); wait > 0 {
+					time.AfterFunc(wait, func() { h.advanceBatch(batchID) })
+					return
+				}
+			}
+		} else {
+			ro := b.Rollout
+			if ro.MaxFailPercent != nil && finished > 0 && failed*100 > *ro.MaxFailPercent*finished {
+				h.stopBatch(b, runs, fmt.Sprintf("Stopped after batch %d of %d: %d of %d finished runs failed, more than the %d%% allowed.",
+					b.Phase, b.Phases, failed, finished, *ro.MaxFailPercent))
+				return
+			}
+			if b.Phase >= b.Phases {
+				status := "success"
+				if failed > 0 {
+					status = "failed"
+				}
+				h.finishBatch(b, status, "")
+				return
+			}
+			if ro.RequireApproval {
+				msg := fmt.Sprintf("Batch %d of %d finished; waiting for approval to start batch %d.", b.Phase, b.Phases, b.Phase+1)
+				if err := h.batches.SetState(b.ID, "awaiting_approval", b.Phase, msg, nil); err != nil {
+					log.Printf("[batches] update batch %s: %v", b.ID, err)
+				}
+				return
+			}
+			if ro.PauseSeconds > 0 {
+				pause := time.Duration(ro.PauseSeconds) * time.Second
+				next := time.Now().Add(pause)
+				msg := fmt.Sprintf("Batch %d of %d finished; pausing before batch %d.", b.Phase, b.Phases, b.Phase+1)
+				if err := h.batches.SetState(b.ID, "paused", b.Phase, msg, &next); err != nil {
+					log.Printf("[batches] update batch %s: %v", b.ID, err)
+					return
+				}
+				time.AfterFunc(pause, func() { h.advanceBatch(batchID) })
+				return
+			}
+		}
+
+		if h.startPhase(b, runs, b.Phase+1) > 0 {
+			return
+		}
+		// Every run of that batch was cancelled before it started; go on.
+	}
+}
+
+// startPhase queues the pending runs of rollout batch phase and returns how
+// many it queued. The caller holds batchMu.
+func (h *RunsHandler) startPhase(b *models.Batch, runs []*models.Run, phase int) int {
+	if err := h.batches.SetState(b.ID, "running", phase, "", nil); err != nil {
+		log.Printf("[batches] update batch %s: %v", b.ID, err)
+		return 0
+	}
+	started := 0
+	for _, r := range runs {
+		if r.BatchPhase != phase || r.Status != "pending" {
+			continue
+		}
+		if err := h.queue.Enqueue(r.ID, r.Inventory); err != nil {
+			h.runs.Finish(r.ID, "failed", fmt.Sprintf("queue run: %v", err))
+			continue
+		}
+		started++
+	}
+	if started > 0 {
+		h.wakeWorkers()
+	}
+	return started
+}
+
+// stopBatch ends a rollout early, cancelling the runs of the batches that
+// have not started. The caller holds batchMu.
+func (h *RunsHandler) stopBatch(b *models.Batch, runs []*models.Run, msg string) {
+	for _, r := range runs {
+		if r.BatchPhase > b.Phase && r.Status == "pending" {
+			if _, err := h.runs.CancelHeld(r.ID, "Not started: "+msg); err != nil {
+				log.Printf("[batches] cancel run %s: %v", r.ID, err)
+			}
+		}
+	}
+	h.finishBatch(b, "stopped", msg)
+}
+
+// finishBatch ends a batch with a final status and sends the form's
+// notification once for the whole batch. The caller holds batchMu.
+func (h *RunsHandler) finishBatch(b *models.Batch, status, msg string) {
+	if err := h.batches.Finish(b.ID, status, msg); err != nil {
+		log.Printf("[batches] finish batch %s: %v", b.ID, err)
+		return
+	}
+	if b.FormID == nil {
+		return
+	}
+	form, err := h.forms.Get(*b.FormID)
+	if err != nil || form == nil || (form.NotifyWebhook == "" && form.NotifyEmail == "") {
+		return
+	}
+	runs, err := h.runs.ListBatch(b.ID)
+	if err != nil {
+		log.Printf("[batches] load runs of batch %s: %v", b.ID, err)
+		return
+	}
+	members := make([]notify.BatchRun, 0, len(runs))
+	for _, r := range runs {
+		members = append(members, notify.BatchRun{RunID: r.ID, Host: memberHost(r.Inventory), Status: r.Status})
+	}
+	go notify.SendBatch(form.NotifyWebhook, form.NotifyEmail, b.ID, status, form.Name, batchCounts(runs), members)
+}
+
+// batchCounts counts a batch's member runs per run status.
+func batchCounts(runs []*models.Run) map[string]int {
+	counts := map[string]int{"pending": 0, "running": 0, "success": 0, "failed": 0, "cancelled": 0, "timed_out": 0}
+	for _, r := range runs {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+// ResumeBatches picks up unfinished rollouts after a restart. Call it after
+// RecoverQueue.
+func (h *RunsHandler) ResumeBatches() error {
+	ids, err := h.batches.ListActive()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		h.advanceBatch(id)
+	}
+	return nil
+}
+
+// ── Handlers ──────────────────────────────────────────────────────────────────
+
+// GetBatch returns a batch's rollout progress, its member runs and their
+// counts per status.
+func (h *RunsHandler) GetBatch(c *gin.Context) {
+	b, err := h.batches.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+	runs, err := h.runs.ListBatch(b.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	b.Members = make([]*models.BatchMember, 0, len(runs))
+	for _, r := range runs {
+		b.Members = append(b.Members, &models.BatchMember{
+			RunID:      r.ID,
+			Host:       memberHost(r.Inventory),
+			Phase:      r.BatchPhase,
+			Status:     r.Status,
+			Summary:    r.Summary,
+			StartedAt:  r.StartedAt,
+			FinishedAt: r.FinishedAt,
+		})
+	}
+	b.Counts = batchCounts(runs)
+	end := time.Now()
+	if b.FinishedAt != nil {
+		end = *b.FinishedAt
+	}
+	b.Duration = end.Sub(b.CreatedAt).Seconds()
+	c.JSON(http.StatusOK, b)
+}
+
+// ApproveBatch starts the next rollout batch of a batch waiting for approval.
+func (h *RunsHandler) ApproveBatch(c *gin.Context) {
+	id := c.Param("id")
+	h.batchMu.Lock()
+	b, err := h.batches.Get(id)
+	if err != nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+	if b.Status != "awaiting_approval" {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "batch is not waiting for approval"})
+		return
+	}
+	runs, err := h.runs.ListBatch(id)
+	if err != nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	started := h.startPhase(b, runs, b.Phase+1)
+	h.batchMu.Unlock()
+	if started == 0 {
+		go h.advanceBatch(id)
+	}
+
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "approve", "batch", id, fmt.Sprintf("batch %d of %d", b.Phase+1, b.Phases), c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// CancelBatch cancels every member run of a batch that has not finished:
+// queued and held runs are cancelled, executing runs are stopped, and the
+// batch ends as cancelled once the last of them has stopped.
+func (h *RunsHandler) CancelBatch(c *gin.Context) {
+	id := c.Param("id")
+	uid, uname := auditUser(c)
+	h.batchMu.Lock()
+	b, err := h.batches.Get(id)
+	if err != nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b == nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+	if b.FinishedAt != nil || b.Status == "cancelling" {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "batch has already finished or is being cancelled"})
+		return
+	}
+	if err := h.batches.SetState(id, "cancelling", b.Phase, fmt.Sprintf("Cancelled by %s.", uname), nil); err != nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	runs, err := h.runs.ListBatch(id)
+	if err != nil {
+		h.batchMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cancelled := 0
+	for _, r := range runs {
+		if r.Status != "pending" && r.Status != "running" {
+			continue
+		}
+		stopped, err := h.stopRun(r.ID, uname)
+		if err != nil {
+			log.Printf("[batches] cancel run %s: %v", r.ID, err)
+		}
+		if stopped {
+			cancelled++
+		}
+	}
+	h.batchMu.Unlock()
+	// Finishes the batch now if none of its runs was executing.
+	h.advanceBatch(id)
+
+	h.audit.Log(uid, uname, "cancel", "batch", id, fmt.Sprintf("%d run(s) cancelled", cancelled), c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
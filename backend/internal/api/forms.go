@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/auth"
@@ -207,41 +208,86 @@ func (h *FormsHandler) RevokeWebhookToken(c *gin.Context) {
 }
 
 type formRequest struct {
-	Name            string             `json:"name" binding:"required"`
-	Description     string             `json:"description"`
-	PlaybookID      string             `json:"playbook_id" binding:"required"`
-	PlaybookPath    string             `json:"playbook_path"`
-	ServerID        string             `json:"server_id"`
-	HostID          string             `json:"host_id"`
-	ServerGroupID   string             `json:"server_group_id"`
-	VaultID         *string            `json:"vault_id"`
-	IsQuickAction   bool               `json:"is_quick_action"`
-	ScheduleCron    string             `json:"schedule_cron"`
-	ScheduleEnabled bool               `json:"schedule_enabled"`
-	NotifyWebhook   string             `json:"notify_webhook"`
-	NotifyEmail     string             `json:"notify_email"`
-	Fields          []models.FormField `json:"fields"`
+	Name             string             `json:"name" binding:"required"`
+	Description      string             `json:"description"`
+	PlaybookID       string             `json:"playbook_id" binding:"required"`
+	PlaybookPath     string             `json:"playbook_path"`
+	ServerID         string             `json:"server_id"`
+	HostID           string             `json:"host_id"`
+	ServerGroupID    string             `json:"server_group_id"`
+	InventoryGroupID string             `json:"inventory_group_id"`
+	HostSelector     string             `json:"host_selector"`
+	VaultID          *string            `json:"vault_id"`
+	IsQuickAction    bool               `json:"is_quick_action"`
+	ScheduleCron     string             `json:"schedule_cron"`
+	ScheduleEnabled  bool               `json:"schedule_enabled"`
+	ScheduleCheck    bool               `json:"schedule_check_mode"`
+	ScheduleDiff     bool               `json:"schedule_diff_mode"`
+	RunOptions       models.RunOptions  `json:"run_options"`
+	Overridable      []string           `json:"overridable_options"`
+	MaxConcurrent    int                `json:"max_concurrent_runs"`
+	MaxRuntime       int                `json:"max_runtime_minutes"`
+	Rollout          models.Rollout     `json:"rollout"`
+	GroupRunMode     string             `json:"group_run_mode"`
+	NotifyWebhook    string             `json:"notify_webhook"`
+	NotifyEmail      string             `json:"notify_email"`
+	Fields           []models.FormField `json:"fields"`
+}
+
+// parseGroupRunMode validates a form's group_run_mode; "" is per_member.
+// per_member launches one run per server-group member, single launches one
+// run whose inventory holds every member.
+func parseGroupRunMode(mode string) (string, error) {
+	switch mode {
+	case "", "per_member":
+		return "per_member", nil
+	case "single":
+		return mode, nil
+	}
+	return "", fmt.Errorf("group_run_mode must be per_member or single")
 }
 
 // parseFormIDs extracts nullable runner/target IDs from a formRequest.
 // server_id is the Job Runner (always required).
-// Either host_id (single host) or server_group_id (host group) must be provided as the target.
-func parseFormIDs(req formRequest) (serverID *string, hostID *string, serverGroupID *string, err error) {
+// One of host_id (single host), server_group_id (host group),
+// inventory_group_id (inventory group) or host_selector (hosts by label) must
+// be provided as the target.
+func parseFormIDs(req formRequest) (serverID *string, hostID *string, serverGroupID *string, inventoryGroupID *string, err error) {
 	if req.ServerID == "" {
-		return nil, nil, nil, fmt.Errorf("server_id (job runner) is required")
+		return nil, nil, nil, nil, fmt.Errorf("server_id (job runner) is required")
 	}
 	sid := req.ServerID
 	serverID = &sid
 
 	if req.ServerGroupID != "" {
 		sgid := req.ServerGroupID
-		return serverID, nil, &sgid, nil
+		return serverID, nil, &sgid, nil, nil
+	}
+	if req.InventoryGroupID != "" {
+		igid := req.InventoryGroupID
+		return serverID, nil, nil, &igid, nil
 	}
 	if req.HostID != "" {
 		hid := req.HostID
-		return serverID, &hid, nil, nil
+		return serverID, &hid, nil, nil, nil
 	}
-	return nil, nil, nil, fmt.Errorf("either host_id or server_group_id is required as target")
+	if strings.TrimSpace(req.HostSelector) != "" {
+		return serverID, nil, nil, nil, nil
+	}
+	return nil, nil, nil, nil, fmt.Errorf("one of host_id, server_group_id, inventory_group_id or host_selector is required as target")
+}
+
+// parseFormHostSelector returns a form's validated host_selector when it is
+// the form's target, that is when no host or group is set; otherwise "".
+func parseFormHostSelector(req formRequest) (string, error) {
+	if req.ServerGroupID != "" || req.InventoryGroupID != "" || req.HostID != "" {
+		return "", nil
+	}
+	sel := strings.TrimSpace(req.HostSelector)
+	if _, err := parseHostSelector(sel); err != nil {
+		return "", err
+	}
+	return sel, nil
 }
 
 func (h *FormsHandler) Create(c *gin.Context) {
@@ -255,8 +301,34 @@ func (h *FormsHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cron expression: " + err.Error()})
 		return
 	}
+	if err := validateFormRunOptions(req.RunOptions, req.Overridable); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MaxConcurrent < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNegativeConcurrency})
+		return
+	}
+	if req.MaxRuntime < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_runtime_minutes must be 0 (no limit) or more"})
+		return
+	}
+	if err := validateRollout(req.Rollout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groupRunMode, err := parseGroupRunMode(req.GroupRunMode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	serverID, hostID, serverGroupID, err := parseFormIDs(req)
+	serverID, hostID, serverGroupID, inventoryGroupID, err := parseFormIDs(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hostSelector, err := parseFormHostSelector(req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -267,7 +339,7 @@ func (h *FormsHandler) Create(c *gin.Context) {
 		vaultID = nil
 	}
 
-	f, err := h.forms.Create(req.Name, req.Description, req.PlaybookID, req.PlaybookPath, serverID, hostID, serverGroupID, vaultID, req.IsQuickAction, req.ScheduleCron, req.ScheduleEnabled, req.NotifyWebhook, req.NotifyEmail, req.Fields)
+	f, err := h.forms.Create(req.Name, req.Description, req.PlaybookID, req.PlaybookPath, serverID, hostID, serverGroupID, inventoryGroupID, hostSelector, vaultID, req.IsQuickAction, req.ScheduleCron, req.ScheduleEnabled, req.ScheduleCheck, req.ScheduleDiff, req.RunOptions, req.Overridable, req.MaxConcurrent, req.MaxRuntime, req.Rollout, groupRunMode, req.NotifyWebhook, req.NotifyEmail, req.Fields)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -294,8 +366,34 @@ func (h *FormsHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cron expression: " + err.Error()})
 		return
 	}
+	if err := validateFormRunOptions(req.RunOptions, req.Overridable); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MaxConcurrent < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNegativeConcurrency})
+		return
+	}
+	if req.MaxRuntime < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_runtime_minutes must be 0 (no limit) or more"})
+		return
+	}
+	if err := validateRollout(req.Rollout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groupRunMode, err := parseGroupRunMode(req.GroupRunMode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	serverID, hostID, serverGroupID, err := parseFormIDs(req)
+	serverID, hostID, serverGroupID, inventoryGroupID, err := parseFormIDs(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hostSelector, err := parseFormHostSelector(req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -306,7 +404,7 @@ func (h *FormsHandler) Update(c *gin.Context) {
 		vaultID = nil
 	}
 
-	f, err := h.forms.Update(id, req.Name, req.Description, req.PlaybookID, req.PlaybookPath, serverID, hostID, serverGroupID, vaultID, req.IsQuickAction, req.ScheduleCron, req.ScheduleEnabled, req.NotifyWebhook, req.NotifyEmail, req.Fields)
+	f, err := h.forms.Update(id, req.Name, req.Description, req.PlaybookID, req.PlaybookPath, serverID, hostID, serverGroupID, inventoryGroupID, hostSelector, vaultID, req.IsQuickAction, req.ScheduleCron, req.ScheduleEnabled, req.ScheduleCheck, req.ScheduleDiff, req.RunOptions, req.Overridable, req.MaxConcurrent, req.MaxRuntime, req.Rollout, groupRunMode, req.NotifyWebhook, req.NotifyEmail, req.Fields)
 	if err != nil || f == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "form not found"})
 		return
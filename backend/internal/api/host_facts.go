@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// hostCheckTimeout bounds an ad-hoc host check such as gathering facts, from
+// connecting to the job runner to the last host's result.
+const hostCheckTimeout = 5 * time.Minute
+
+// HostFactsHandler serves the facts cache: the ansible_facts last seen for
+// each host, from a run's setup or gather_facts task or from gathering them
+// on demand.
+type HostFactsHandler struct {
+	hosts    *store.HostStore
+	facts    *store.HostFactsStore
+	servers  *store.ServerStore
+	sshCerts *store.SSHCertStore
+	audit    *store.AuditStore
+}
+
+func newHostFactsHandler(hosts *store.HostStore, facts *store.HostFactsStore, servers *store.ServerStore, sshCerts *store.SSHCertStore, audit *store.AuditStore) *HostFactsHandler {
+	return &HostFactsHandler{hosts: hosts, facts: facts, servers: servers, sshCerts: sshCerts, audit: audit}
+}
+
+// Get returns a host's cached facts, 404 when none have been seen.
+func (h *HostFactsHandler) Get(c *gin.Context) {
+	host, err := h.hosts.Get(c.Param("id"))
+	if err != nil || host == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "host not found"})
+		return
+	}
+	facts, err := h.facts.Get(host.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if facts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no facts gathered for this host yet"})
+		return
+	}
+	c.JSON(http.StatusOK, facts)
+}
+
+// Gather runs the setup module against a host from the job runner in
+// server_id, caches the facts and returns them. A host the runner cannot
+// reach answers 502 with Ansible's message.
+func (h *HostFactsHandler) Gather(c *gin.Context) {
+	var req struct {
+		ServerID string `json:"server_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.hosts.Get(c.Param("id"))
+	if err != nil || host == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "host not found"})
+		return
+	}
+	server, err := h.servers.Get(req.ServerID)
+	if err != nil || server == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job runner not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), hostCheckTimeout)
+	defer cancel()
+	results, output, err := runAdHoc(ctx, h.servers, h.sshCerts, server, []*models.Host{host}, "setup", "")
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	ev := results[host.Name]
+	if ev == nil || ev.Facts == nil || (ev.Status != "ok" && ev.Status != "changed") {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "gather facts: " + adHocFailure(ev, output)})
+		return
+	}
+	facts, err := h.facts.Save(host.ID, ev.Facts, "gather", nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "gather-facts", "host", host.ID, fmt.Sprintf("from runner %s", server.Name), c.ClientIP())
+	c.JSON(http.StatusOK, facts)
+}
+
+// cacheHostFacts stores facts reported for an inventory host name on every
+// host with that name; names the Hosts table doesn't know are ignored.
+func cacheHostFacts(hosts *store.HostStore, factsStore *store.HostFactsStore, name string, facts map[string]interface{}, source string, runID *string) error {
+	list, err := hosts.ListByName(name)
+	if err != nil {
+		return err
+	}
+	for _, host := range list {
+		if _, err := factsStore.Save(host.ID, facts, source, runID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var factKeyRe = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+// factFilter is a parsed ?fact= term: the JSON paths of a fact, with and
+// without Ansible's ansible_ prefix, and the value to match.
+type factFilter struct {
+	paths []string
+	value string
+}
+
+// parseFactFilter parses key=value, where key is a fact name with or
+// without its ansible_ prefix and may reach into a dict fact with dots
+// (default_ipv4.address). value is compared as text; * and ? glob.
+func parseFactFilter(expr string) (factFilter, error) {
+	key, value, ok := strings.Cut(expr, "=")
+	key = strings.TrimSpace(key)
+	if !ok || !factKeyRe.MatchString(key) {
+		return factFilter{}, fmt.Errorf("fact filter %q must be key=value, e.g. os_family=Debian", expr)
+	}
+	f := factFilter{paths: []string{"$." + key}, value: strings.TrimSpace(value)}
+	if !strings.HasPrefix(key, "ansible_") {
+		f.paths = append(f.paths, "$.ansible_"+key)
+	}
+	return f, nil
+}
+
+// matchingHostIDs returns the IDs of the hosts whose cached facts match f.
+func (f factFilter) matchingHostIDs(facts *store.HostFactsStore) (map[string]bool, error) {
+	ids := map[string]bool{}
+	for _, path := range f.paths {
+		matched, err := facts.HostIDsMatching(path, f.value)
+		if err != nil {
+			return nil, err
+		}
+		for id := range matched {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+// filterHostsByFacts keeps the hosts whose cached facts match every filter.
+func filterHostsByFacts(hosts []*models.Host, facts *store.HostFactsStore, filters []factFilter) ([]*models.Host, error) {
+	for _, f := range filters {
+		ids, err := f.matchingHostIDs(facts)
+		if err != nil {
+			return nil, err
+		}
+		kept := []*models.Host{}
+		for _, host := range hosts {
+			if ids[host.ID] {
+				kept = append(kept, host)
+			}
+		}
+		hosts = kept
+	}
+	return hosts, nil
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/scheduler"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// fleetPingKey is the scheduler key of the scheduled fleet ping.
+const fleetPingKey = "host-ping:fleet"
+
+// HostPingHandler checks that a job runner can reach hosts, by running
+// Ansible's ping module against them with their vars and SSH certs, and
+// records the outcome on each host. ServersHandler.Test only checks the
+// app's own connection to a runner.
+type HostPingHandler struct {
+	hosts    *store.HostStore
+	servers  *store.ServerStore
+	sshCerts *store.SSHCertStore
+	settings *store.SettingsStore
+	audit    *store.AuditStore
+	sched    *scheduler.Scheduler
+}
+
+func NewHostPingHandler(hosts *store.HostStore, servers *store.ServerStore, sshCerts *store.SSHCertStore, settings *store.SettingsStore, audit *store.AuditStore, sched *scheduler.Scheduler) *HostPingHandler {
+	return &HostPingHandler{hosts: hosts, servers: servers, sshCerts: sshCerts, settings: settings, audit: audit, sched: sched}
+}
+
+// hostPingResult is the outcome of a bulk ping: a count per status and the
+// pinged hosts with their new last check.
+type hostPingResult struct {
+	OK          int            `json:"ok"`
+	Unreachable int            `json:"unreachable"`
+	Failed      int            `json:"failed"`
+	Hosts       []*models.Host `json:"hosts"`
+}
+
+// Ping pings one host from the job runner in server_id and returns the host
+// with its new last check. A host that does not answer is a check result,
+// not an error; 502 means the job runner itself could not run Ansible, and
+// the host's last check is left as it was.
+func (h *HostPingHandler) Ping(c *gin.Context) {
+	var req struct {
+		ServerID string `json:"server_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.hosts.Get(c.Param("id"))
+	if err != nil || host == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "host not found"})
+		return
+	}
+	server, err := h.servers.Get(req.ServerID)
+	if err != nil || server == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job runner not found"})
+		return
+	}
+
+	result, err := h.ping(c.Request.Context(), server, []*models.Host{host})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "ping", "host", host.ID, fmt.Sprintf("from runner %s", server.Name), c.ClientIP())
+	c.JSON(http.StatusOK, result.Hosts[0])
+}
+
+// PingMany pings the hosts in host_ids, or those matching a label selector,
+// or every host when neither is given, in a single ansible run from the job
+// runner in server_id.
+func (h *HostPingHandler) PingMany(c *gin.Context) {
+	var req struct {
+		ServerID string   `json:"server_id" binding:"required"`
+		HostIDs  []string `json:"host_ids"`
+		Selector string   `json:"selector"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	server, err := h.servers.Get(req.ServerID)
+	if err != nil || server == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job runner not found"})
+		return
+	}
+	sel, err := parseHostSelector(req.Selector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	all, err := h.hosts.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hosts := selectHosts(all, sel)
+	if len(req.HostIDs) > 0 {
+		wanted := make(map[string]bool, len(req.HostIDs))
+		for _, id := range req.HostIDs {
+			wanted[id] = true
+		}
+		kept := []*models.Host{}
+		for _, host := range hosts {
+			if wanted[host.ID] {
+				kept = append(kept, host)
+			}
+		}
+		hosts = kept
+	}
+	if len(hosts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no hosts to ping"})
+		return
+	}
+
+	result, err := h.ping(c.Request.Context(), server, hosts)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "ping", "host", "", fmt.Sprintf("%d host(s) from runner %s", len(hosts), server.Name), c.ClientIP())
+	c.JSON(http.StatusOK, result)
+}
+
+// ping runs the ping module against hosts from server and records each
+// host's outcome. It fails, recording nothing, only when Ansible could not
+// be run at all.
+func (h *HostPingHandler) ping(ctx context.Context, server *models.Server, hosts []*models.Host) (*hostPingResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostCheckTimeout)
+	defer cancel()
+	results, output, err := runAdHoc(ctx, h.servers, h.sshCerts, server, hosts, "ping", "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := &hostPingResult{Hosts: make([]*models.Host, 0, len(hosts))}
+	for _, host := range hosts {
+		ev := results[host.Name]
+		status, message := "ok", ""
+		switch {
+		case ev != nil && ev.Status == "ok":
+			res.OK++
+		case ev != nil && ev.Status == "unreachable":
+			status, message = "unreachable", ev.Msg
+			res.Unreachable++
+		default:
+			status, message = "failed", adHocFailure(ev, output)
+			res.Failed++
+		}
+		if err := h.hosts.SetCheck(host.ID, status, message, now); err != nil {
+			return nil, err
+		}
+		host.LastCheckStatus, host.LastCheckAt, host.LastCheckError = status, &now, message
+		res.Hosts = append(res.Hosts, host)
+	}
+	return res, nil
+}
+
+// hostPingSettings is the scheduled fleet ping's configuration, kept in the
+// settings table: a cron expression (empty disables it) and the job runner
+// it pings from, with the time of its next run.
+type hostPingSettings struct {
+	Cron      string     `json:"host_ping_cron"`
+	ServerID  string     `json:"host_ping_server_id"`
+	NextRunAt *time.Time `json:"next_run_at"`
+}
+
+func (h *HostPingHandler) loadSettings() (*hostPingSettings, error) {
+	all, err := h.settings.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	s := &hostPingSettings{Cron: all["host_ping_cron"], ServerID: all["host_ping_server_id"]}
+	if h.sched != nil {
+		s.NextRunAt = h.sched.NextRunAt(fleetPingKey)
+	}
+	return s, nil
+}
+
+// ScheduleFleetPing registers the scheduled fleet ping from the settings,
+// or removes it when no cron expression is set. Called at startup and
+// whenever the settings change.
+func (h *HostPingHandler) ScheduleFleetPing() error {
+	if h.sched == nil {
+		return nil
+	}
+	s, err := h.loadSettings()
+	if err != nil {
+		return err
+	}
+	h.sched.UpsertFunc(fleetPingKey, s.Cron, h.pingFleet)
+	return nil
+}
+
+// pingFleet pings every host from the configured job runner.
+func (h *HostPingHandler) pingFleet() {
+	s, err := h.loadSettings()
+	if err != nil {
+		log.Printf("[host-ping] load settings: %v", err)
+		return
+	}
+	server, err := h.servers.Get(s.ServerID)
+	if err != nil || server == nil {
+		log.Printf("[host-ping] scheduled fleet ping: job runner %q not found", s.ServerID)
+		return
+	}
+	hosts, err := h.hosts.List()
+	if err != nil {
+		log.Printf("[host-ping] load hosts: %v", err)
+		return
+	}
+	if len(hosts) == 0 {
+		return
+	}
+	res, err := h.ping(context.Background(), server, hosts)
+	if err != nil {
+		log.Printf("[host-ping] scheduled fleet ping from %q: %v", server.Name, err)
+		return
+	}
+	log.Printf("[host-ping] scheduled fleet ping from %q: %d ok, %d unreachable, %d failed",
+		server.Name, res.OK, res.Unreachable, res.Failed)
+}
+
+// GetSettings returns the scheduled fleet ping's configuration.
+func (h *HostPingHandler) GetSettings(c *gin.Context) {
+	s, err := h.loadSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// UpdateSettings saves the scheduled fleet ping's configuration and
+// reschedules it. A schedule needs a job runner to ping from.
+func (h *HostPingHandler) UpdateSettings(c *gin.Context) {
+	var req hostPingSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Cron = strings.TrimSpace(req.Cron)
+	if err := scheduler.ValidateCron(req.Cron); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cron expression: " + err.Error()})
+		return
+	}
+	if req.Cron != "" {
+		if req.ServerID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a scheduled fleet ping needs a job runner"})
+			return
+		}
+		if server, err := h.servers.Get(req.ServerID); err != nil || server == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "job runner not found"})
+			return
+		}
+	}
+	if err := h.settings.SetMany(map[string]string{
+		"host_ping_cron":      req.Cron,
+		"host_ping_server_id": req.ServerID,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.ScheduleFleetPing(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.GetSettings(c)
+}
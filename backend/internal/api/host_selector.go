@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+// hostSelector is a parsed label selector such as
+// "env=prod,role in (web,api),!canary". A host matches when it matches
+// every term; the empty selector matches every host.
+type hostSelector []selectorTerm
+
+type selectorOp string
+
+const (
+	selectorExists    selectorOp = "exists"
+	selectorNotExists selectorOp = "!exists"
+	selectorEq        selectorOp = "="
+	selectorNotEq     selectorOp = "!="
+	selectorIn        selectorOp = "in"
+	selectorNotIn     selectorOp = "notin"
+)
+
+type selectorTerm struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+var (
+	labelKeyRe   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+	labelValueRe = regexp.MustCompile(`^[A-Za-z0-9._/-]*$`)
+	setTermRe    = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// validateLabels checks that host labels can be written in a selector: keys
+// of letters, digits and . _ / - starting with a letter or digit, and values
+// of the same characters.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelKeyRe.MatchString(k) {
+			return fmt.Errorf("label key %q must be letters, digits, '.', '_', '/' or '-', starting with a letter or digit", k)
+		}
+		if !labelValueRe.MatchString(v) {
+			return fmt.Errorf("label %s value %q must be letters, digits, '.', '_', '/' or '-'", k, v)
+		}
+	}
+	return nil
+}
+
+// parseHostSelector parses a comma-separated list of terms:
+//
+//	key              the host has label key
+//	!key             the host does not have label key
+//	key=value        label key is value (key==value works too)
+//	key!=value       label key is not value, or is missing
+//	key in (a,b)     label key is one of the values
+//	key notin (a,b)  label key is none of the values, or is missing
+func parseHostSelector(expr string) (hostSelector, error) {
+	parts, err := splitSelectorTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+	sel := hostSelector{}
+	for _, part := range parts {
+		term, err := parseSelectorTerm(part)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, term)
+	}
+	return sel, nil
+}
+
+// splitSelectorTerms splits expr on the commas that are not inside a value
+// set's parentheses.
+func splitSelectorTerms(expr string) ([]string, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range expr {
+		switch r {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("selector %q: nested parentheses", expr)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("selector %q: unbalanced parentheses", expr)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("selector %q: unbalanced parentheses", expr)
+	}
+	return append(parts, expr[start:]), nil
+}
+
+func parseSelectorTerm(s string) (selectorTerm, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return selectorTerm{}, fmt.Errorf("selector has an empty term")
+	}
+	var t selectorTerm
+	if m := setTermRe.FindStringSubmatch(s); m != nil {
+		t = selectorTerm{key: m[1], op: selectorOp(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			v = strings.TrimSpace(v)
+			if !labelValueRe.MatchString(v) {
+				return selectorTerm{}, fmt.Errorf("selector term %q: invalid value %q", s, v)
+			}
+			t.values = append(t.values, v)
+		}
+	} else if i := strings.Index(s, "!="); i >= 0 {
+		t = selectorTerm{key: s[:i], op: selectorNotEq, values: []string{s[i+2:]}}
+	} else if i := strings.Index(s, "="); i >= 0 {
+		t = selectorTerm{key: s[:i], op: selectorEq, values: []string{strings.TrimPrefix(s[i+1:], "=")}}
+	} else if strings.HasPrefix(s, "!") {
+		t = selectorTerm{key: s[1:], op: selectorNotExists}
+	} else {
+		t = selectorTerm{key: s, op: selectorExists}
+	}
+	t.key = strings.TrimSpace(t.key)
+	if !labelKeyRe.MatchString(t.key) {
+		return selectorTerm{}, fmt.Errorf("selector term %q: invalid label key %q", s, t.key)
+	}
+	if t.op == selectorEq || t.op == selectorNotEq {
+		t.values[0] = strings.TrimSpace(t.values[0])
+		if !labelValueRe.MatchString(t.values[0]) {
+			return selectorTerm{}, fmt.Errorf("selector term %q: invalid value %q", s, t.values[0])
+		}
+	}
+	return t, nil
+}
+
+func (sel hostSelector) matches(labels map[string]string) bool {
+	for _, t := range sel {
+		v, ok := labels[t.key]
+		var match bool
+		switch t.op {
+		case selectorExists:
+			match = ok
+		case selectorNotExists:
+			match = !ok
+		case selectorEq:
+			match = ok && v == t.values[0]
+		case selectorNotEq:
+			match = !ok || v != t.values[0]
+		case selectorIn:
+			match = ok && containsString(t.values, v)
+		case selectorNotIn:
+			match = !ok || !containsString(t.values, v)
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// selectHosts returns the hosts whose labels match sel, in their order.
+func selectHosts(hosts []*models.Host, sel hostSelector) []*models.Host {
+	matched := []*models.Host{}
+	for _, h := range hosts {
+		if sel.matches(h.Labels) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
@@ -3,34 +3,56 @@ package api
 import (
 	"net/http"
 
-	"github.com/brettjrea/ansible-frontend/internal/models"
 	"github.com/brettjrea/ansible-frontend/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
 type HostsHandler struct {
-	hosts *store.HostStore
-	audit *store.AuditStore
+	hosts  *store.HostStore
+	groups *store.InventoryGroupStore
+	certs  *store.SSHCertStore
+	facts  *store.HostFactsStore
+	audit  *store.AuditStore
 }
 
-func newHostsHandler(hosts *store.HostStore, audit *store.AuditStore) *HostsHandler {
-	return &HostsHandler{hosts: hosts, audit: audit}
+func newHostsHandler(hosts *store.HostStore, groups *store.InventoryGroupStore, certs *store.SSHCertStore, facts *store.HostFactsStore, audit *store.AuditStore) *HostsHandler {
+	return &HostsHandler{hosts: hosts, groups: groups, certs: certs, facts: facts, audit: audit}
 }
 
-func NewHostsHandler(hosts *store.HostStore, audit *store.AuditStore) *HostsHandler {
-	return newHostsHandler(hosts, audit)
+func NewHostsHandler(hosts *store.HostStore, groups *store.InventoryGroupStore, certs *store.SSHCertStore, facts *store.HostFactsStore, audit *store.AuditStore) *HostsHandler {
+	return newHostsHandler(hosts, groups, certs, facts, audit)
 }
 
+// List returns every host, or with ?selector= only the hosts whose labels
+// match the selector, so a form's host_selector can be previewed. Each
+// ?fact=key=value (e.g. os_family=Debian) further keeps only the hosts whose
+// cached facts match.
 func (h *HostsHandler) List(c *gin.Context) {
+	sel, err := parseHostSelector(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var filters []factFilter
+	for _, expr := range c.QueryArray("fact") {
+		f, err := parseFactFilter(expr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filters = append(filters, f)
+	}
 	list, err := h.hosts.List()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if list == nil {
-		list = []*models.Host{}
+	matched, err := filterHostsByFacts(selectHosts(list, sel), h.facts, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	c.JSON(http.StatusOK, list)
+	c.JSON(http.StatusOK, matched)
 }
 
 func (h *HostsHandler) Get(c *gin.Context) {
@@ -44,17 +66,22 @@ func (h *HostsHandler) Get(c *gin.Context) {
 
 func (h *HostsHandler) Create(c *gin.Context) {
 	var req struct {
-		Name        string            `json:"name" binding:"required"`
-		Address     string            `json:"address" binding:"required"`
-		Description string            `json:"description"`
-		SSHCertID   *string           `json:"ssh_cert_id"`
-		Vars        map[string]string `json:"vars"`
+		Name        string                 `json:"name" binding:"required"`
+		Address     string                 `json:"address" binding:"required"`
+		Description string                 `json:"description"`
+		SSHCertID   *string                `json:"ssh_cert_id"`
+		Vars        map[string]interface{} `json:"vars"`
+		Labels      map[string]string      `json:"labels"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	host, err := h.hosts.Create(req.Name, req.Address, req.Description, req.SSHCertID, req.Vars)
+	if err := validateLabels(req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.hosts.Create(req.Name, req.Address, req.Description, req.SSHCertID, req.Vars, req.Labels)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -67,17 +94,22 @@ func (h *HostsHandler) Create(c *gin.Context) {
 func (h *HostsHandler) Update(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
-		Name        string            `json:"name" binding:"required"`
-		Address     string            `json:"address" binding:"required"`
-		Description string            `json:"description"`
-		SSHCertID   *string           `json:"ssh_cert_id"`
-		Vars        map[string]string `json:"vars"`
+		Name        string                 `json:"name" binding:"required"`
+		Address     string                 `json:"address" binding:"required"`
+		Description string                 `json:"description"`
+		SSHCertID   *string                `json:"ssh_cert_id"`
+		Vars        map[string]interface{} `json:"vars"`
+		Labels      map[string]string      `json:"labels"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	host, err := h.hosts.Update(id, req.Name, req.Address, req.Description, req.SSHCertID, req.Vars)
+	if err := validateLabels(req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.hosts.Update(id, req.Name, req.Address, req.Description, req.SSHCertID, req.Vars, req.Labels)
 	if err != nil || host == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "host not found"})
 		return
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// exportInventory is the Hosts table as an Ansible inventory. hostVars hold
+// each host's vars plus ansible_host and, for hosts with an SSH cert, an
+// ansible_ssh_private_key_file placeholder.
+type exportInventory struct {
+	hosts    []string // sorted
+	hostVars map[string]map[string]interface{}
+	groups   []exportGroup // sorted by name
+	usesKeys bool
+}
+
+type exportGroup struct {
+	name     string
+	hosts    []string
+	vars     map[string]interface{}
+	children []string
+}
+
+var keyFileSlugRe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Export renders every host, its vars and the inventory groups as an
+// inventory file: ?format=ini (default), yaml or json (the format of
+// `ansible-inventory --list`). SSH certs are written as
+// ansible_ssh_private_key_file=<key_dir>/<cert name>, key_dir defaulting to
+// ~/.ssh, since the keys themselves never leave the server.
+func (h *HostsHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "ini")
+	keyDir := strings.TrimSuffix(c.DefaultQuery("key_dir", "~/.ssh"), "/")
+
+	inv, err := h.exportInventory(keyDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body []byte
+	var contentType, ext string
+	switch format {
+	case "ini":
+		body, contentType, ext = []byte(inv.renderINI()), "text/plain; charset=utf-8", "ini"
+	case "yaml", "yml":
+		body, err = inv.renderYAML()
+		contentType, ext = "application/yaml", "yml"
+	case "json":
+		body, err = inv.renderJSON()
+		contentType, ext = "application/json", "json"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ini, yaml or json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="inventory.`+ext+`"`)
+	c.Data(http.StatusOK, contentType, body)
+}
+
+func (h *HostsHandler) exportInventory(keyDir string) (*exportInventory, error) {
+	hosts, err := h.hosts.List()
+	if err != nil {
+		return nil, fmt.Errorf("load hosts: %w", err)
+	}
+	groups, err := h.groups.List()
+	if err != nil {
+		return nil, fmt.Errorf("load inventory groups: %w", err)
+	}
+	certs, err := h.certs.List()
+	if err != nil {
+		return nil, fmt.Errorf("load SSH certs: %w", err)
+	}
+	keyFiles := make(map[string]string, len(certs))
+	for _, cert := range certs {
+		slug := strings.Trim(keyFileSlugRe.ReplaceAllString(strings.ToLower(cert.Name), "-"), "-")
+		if slug == "" {
+			slug = cert.ID
+		}
+		keyFiles[cert.ID] = path.Join(keyDir, slug)
+	}
+
+	inv := &exportInventory{hostVars: make(map[string]map[string]interface{}, len(hosts))}
+	hostName := make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		hostName[host.ID] = host.Name
+		vars := inventoryHostVars(host.Name, host.Address, host.Vars)
+		if host.SSHCertID != nil && keyFiles[*host.SSHCertID] != "" {
+			vars["ansible_ssh_private_key_file"] = keyFiles[*host.SSHCertID]
+			inv.usesKeys = true
+		}
+		if _, dup := inv.hostVars[host.Name]; !dup {
+			inv.hosts = append(inv.hosts, host.Name)
+		}
+		inv.hostVars[host.Name] = vars
+	}
+	sort.Strings(inv.hosts)
+
+	groupName := make(map[string]string, len(groups))
+	for _, g := range groups {
+		groupName[g.ID] = g.Name
+	}
+	for _, g := range groups {
+		eg := exportGroup{name: g.Name, vars: g.Vars}
+		for _, id := range g.HostIDs {
+			if name, ok := hostName[id]; ok {
+				eg.hosts = append(eg.hosts, name)
+			}
+		}
+		for _, id := range g.ChildIDs {
+			if name, ok := groupName[id]; ok {
+				eg.children = append(eg.children, name)
+			}
+		}
+		inv.groups = append(inv.groups, eg)
+	}
+	return inv, nil
+}
+
+func (inv *exportInventory) header() string {
+	if !inv.usesKeys {
+		return ""
+	}
+	return "# ansible_ssh_private_key_file paths are placeholders: copy each host's SSH key there.\n\n"
+}
+
+// renderINI writes every host with its vars under [all], then a section per
+// group listing its hosts, with [name:vars] and [name:children]. Host and
+// group vars are written as Python literals, which Ansible's INI parser
+// evaluates back to strings, numbers, booleans, lists and dicts; only host
+// lines are shell-split and need iniValue's extra quoting.
+func (inv *exportInventory) renderINI() string {
+	var b strings.Builder
+	b.WriteString(inv.header())
+	b.WriteString("[all]\n")
+	for _, name := range inv.hosts {
+		vars := inv.hostVars[name]
+		b.WriteString(name)
+		for _, k := range sortedNames(vars) {
+			b.WriteString(" " + k + "=" + iniValue(vars[k]))
+		}
+		b.WriteString("\n")
+	}
+	for _, g := range inv.groups {
+		fmt.Fprintf(&b, "\n[%s]\n", g.name)
+		for _, host := range g.hosts {
+			b.WriteString(host + "\n")
+		}
+		if len(g.vars) > 0 {
+			fmt.Fprintf(&b, "\n[%s:vars]\n", g.name)
+			for _, k := range sortedNames(g.vars) {
+				b.WriteString(k + "=" + pythonLiteral(g.vars[k]) + "\n")
+			}
+		}
+		if len(g.children) > 0 {
+			fmt.Fprintf(&b, "\n[%s:children]\n%s\n", g.name, strings.Join(g.children, "\n"))
+		}
+	}
+	return b.String()
+}
+
+// renderYAML writes every host with its vars under all.hosts and every group
+// under all.children, with its hosts, vars and child groups.
+func (inv *exportInventory) renderYAML() ([]byte, error) {
+	hosts := make(map[string]interface{}, len(inv.hosts))
+	for _, name := range inv.hosts {
+		hosts[name] = inv.hostVars[name]
+	}
+	all := map[string]interface{}{"hosts": hosts}
+	if len(inv.groups) > 0 {
+		children := make(map[string]interface{}, len(inv.groups))
+		for _, g := range inv.groups {
+			group := map[string]interface{}{}
+			if len(g.hosts) > 0 {
+				members := make(map[string]interface{}, len(g.hosts))
+				for _, host := range g.hosts {
+					members[host] = map[string]string{}
+				}
+				group["hosts"] = members
+			}
+			if len(g.vars) > 0 {
+				group["vars"] = g.vars
+			}
+			if len(g.children) > 0 {
+				nested := make(map[string]interface{}, len(g.children))
+				for _, child := range g.children {
+					nested[child] = map[string]string{}
+				}
+				group["children"] = nested
+			}
+			children[g.name] = group
+		}
+		all["children"] = children
+	}
+	var buf bytes.Buffer
+	buf.WriteString(inv.header())
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string]interface{}{"all": all}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// renderJSON writes the `ansible-inventory --list` format: host vars under
+// _meta.hostvars, groups with hosts, vars and children, all's children
+// being ungrouped and the groups that are nobody's child.
+func (inv *exportInventory) renderJSON() ([]byte, error) {
+	grouped := map[string]bool{}
+	nested := map[string]bool{}
+	out := map[string]interface{}{}
+	for _, g := range inv.groups {
+		group := map[string]interface{}{}
+		if len(g.hosts) > 0 {
+			group["hosts"] = g.hosts
+		}
+		if len(g.vars) > 0 {
+			group["vars"] = g.vars
+		}
+		if len(g.children) > 0 {
+			group["children"] = g.children
+		}
+		out[g.name] = group
+		for _, host := range g.hosts {
+			grouped[host] = true
+		}
+		for _, child := range g.children {
+			nested[child] = true
+		}
+	}
+	top := []string{"ungrouped"}
+	for _, g := range inv.groups {
+		if !nested[g.name] {
+			top = append(top, g.name)
+		}
+	}
+	ungrouped := []string{}
+	hostVars := make(map[string]interface{}, len(inv.hosts))
+	for _, name := range inv.hosts {
+		hostVars[name] = inv.hostVars[name]
+		if !grouped[name] {
+			ungrouped = append(ungrouped, name)
+		}
+	}
+	ug := map[string]interface{}{}
+	if len(ungrouped) > 0 {
+		ug["hosts"] = ungrouped
+	}
+	out["ungrouped"] = ug
+	out["all"] = map[string]interface{}{"children": top}
+	out["_meta"] = map[string]interface{}{"hostvars": hostVars}
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// iniValue quotes a host var for an INI host line as a Python literal, with
+// its backslashes and quotes escaped for the shell-style splitting of host
+// lines. Ansible evaluates the literal back to the same type, so strings such
+// as "22" or "True" stay strings.
+func iniValue(v interface{}) string {
+	lit := pythonLiteral(v)
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(lit, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// pythonLiteral writes a JSON value in the syntax of Python's
+// ast.literal_eval.
+func pythonLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, e := range v {
+			items[i] = pythonLiteral(e)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for _, k := range sortedNames(v) {
+			items = append(items, strconv.Quote(k)+": "+pythonLiteral(v[k]))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
@@ -3,24 +3,43 @@ package api
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
+	"github.com/brettjrea/ansible-frontend/internal/models"
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
 )
 
 type importedHost struct {
-	Name    string            `json:"name"`
-	Address string            `json:"address"`
-	Vars    map[string]string `json:"vars"`
+	Name    string                 `json:"name"`
+	Address string                 `json:"address"`
+	Vars    map[string]interface{} `json:"vars"`
+	Groups  []string               `json:"groups"`
+}
+
+type importedGroup struct {
+	Name     string                 `json:"name"`
+	Vars     map[string]interface{} `json:"vars"`
+	Children []string               `json:"children"`
 }
 
 type importResult struct {
-	Created []string `json:"created"`
-	Skipped []string `json:"skipped"`
-	Errors  []string `json:"errors"`
+	Format        string   `json:"format"`  // ini | yaml | json, as detected or requested
+	DryRun        bool     `json:"dry_run"` // nothing was written; the lists say what an import would do
+	Created       []string `json:"created"`
+	Updated       []string `json:"updated"` // existing hosts whose address or vars changed, in update mode
+	Skipped       []string `json:"skipped"`
+	Errors        []string `json:"errors"`
+	GroupsCreated []string `json:"groups_created"`
+	GroupsUpdated []string `json:"groups_updated"`
 }
 
 // varsToSkip are inventory connection vars that are either redundant in our
@@ -31,9 +50,31 @@ var varsToSkip = map[string]bool{
 	"ansible_ssh_private_key_file": true,
 }
 
-// Import parses an uploaded Ansible INI inventory file and bulk-creates hosts.
+// Import parses an uploaded Ansible inventory file (INI, YAML or
+// `ansible-inventory --list` JSON) and bulk-creates hosts and the inventory
+// groups they belong to. Groups that already exist (by name) gain the file's
+// hosts and child groups and keep their vars.
+//
+// Query parameters:
+//   - format=ini|yaml|json overrides format detection (see detectInventoryFormat);
+//   - update=true gives existing hosts with the same name the file's address
+//     and host vars instead of skipping them; description, SSH cert and
+//     labels are kept;
+//   - dry_run=true writes nothing and returns what the import would do.
 func (h *HostsHandler) Import(c *gin.Context) {
-	f, _, err := c.Request.FormFile("file")
+	var dryRun, update bool
+	for name, dst := range map[string]*bool{"dry_run": &dryRun, "update": &update} {
+		if v := c.Query(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + ": must be true or false"})
+				return
+			}
+			*dst = b
+		}
+	}
+
+	f, header, err := c.Request.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
 		return
@@ -46,67 +87,243 @@ func (h *HostsHandler) Import(c *gin.Context) {
 		return
 	}
 
-	parsed := parseAnsibleINI(content)
+	format := c.Query("format")
+	if format == "" {
+		format = detectInventoryFormat(header.Filename, content)
+	}
+	var parsed []importedHost
+	var parsedGroups []importedGroup
+	switch format {
+	case "ini":
+		parsed, parsedGroups = parseAnsibleINI(content)
+	case "yaml", "yml":
+		format = "yaml"
+		parsed, parsedGroups, err = parseInventoryYAML(content)
+	case "json":
+		parsed, parsedGroups, err = parseInventoryJSON(content)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ini, yaml or json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	uid, uname := auditUser(c)
 	result := importResult{
-		Created: []string{},
-		Skipped: []string{},
-		Errors:  []string{},
+		Format:        format,
+		DryRun:        dryRun,
+		Created:       []string{},
+		Updated:       []string{},
+		Skipped:       []string{},
+		Errors:        []string{},
+		GroupsCreated: []string{},
+		GroupsUpdated: []string{},
 	}
 
 	existing, _ := h.hosts.List()
-	existingNames := make(map[string]bool, len(existing))
+	hostByName := make(map[string]*models.Host, len(existing))
 	for _, host := range existing {
-		existingNames[host.Name] = true
+		hostByName[host.Name] = host
 	}
 
+	groupHosts := map[string][]string{}
 	for _, ph := range parsed {
-		if existingNames[ph.Name] {
-			result.Skipped = append(result.Skipped, ph.Name)
+		id := ""
+		if host, ok := hostByName[ph.Name]; ok {
+			id = host.ID
+			switch {
+			case !update || (host.Address == ph.Address && sameVars(host.Vars, ph.Vars)):
+				result.Skipped = append(result.Skipped, ph.Name)
+			case dryRun:
+				result.Updated = append(result.Updated, ph.Name)
+			default:
+				if _, uerr := h.hosts.Update(host.ID, host.Name, ph.Address, host.Description, host.SSHCertID, ph.Vars, host.Labels); uerr != nil {
+					result.Errors = append(result.Errors, ph.Name+": "+uerr.Error())
+					continue
+				}
+				h.audit.Log(uid, uname, "update", "host", host.ID, "imported from inventory file", c.ClientIP())
+				result.Updated = append(result.Updated, ph.Name)
+			}
+		} else if dryRun {
+			result.Created = append(result.Created, ph.Name)
+		} else {
+			host, cerr := h.hosts.Create(ph.Name, ph.Address, "", nil, ph.Vars, nil)
+			if cerr != nil {
+				result.Errors = append(result.Errors, ph.Name+": "+cerr.Error())
+				continue
+			}
+			h.audit.Log(uid, uname, "create", "host", host.ID, "imported from inventory file", c.ClientIP())
+			result.Created = append(result.Created, ph.Name)
+			hostByName[ph.Name] = host
+			id = host.ID
+		}
+		for _, g := range ph.Groups {
+			groupHosts[g] = append(groupHosts[g], id)
+		}
+	}
+
+	h.importGroups(c, parsedGroups, groupHosts, dryRun, &result)
+	c.JSON(http.StatusOK, result)
+}
+
+// detectInventoryFormat picks the parser for an uploaded inventory: json
+// when the content starts with '{', yaml for .yml/.yaml files or content
+// whose first line is a "name:" mapping key (as in "all:"), else ini.
+func detectInventoryFormat(filename string, content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return "json"
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".yml", ".yaml":
+		return "yaml"
+	case ".json":
+		return "json"
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
 			continue
 		}
-		host, cerr := h.hosts.Create(ph.Name, ph.Address, "", nil, ph.Vars)
-		if cerr != nil {
-			result.Errors = append(result.Errors, ph.Name+": "+cerr.Error())
+		if line == "---" || (strings.HasSuffix(line, ":") && !strings.ContainsAny(line, " =[")) {
+			return "yaml"
+		}
+		break
+	}
+	return "ini"
+}
+
+// importGroups creates the parsed inventory groups with their hosts, or adds
+// the hosts to groups that already exist, then links child groups. A child
+// link that would make a group its own descendant is reported and skipped.
+// A dry run only reports which groups would be created or updated.
+func (h *HostsHandler) importGroups(c *gin.Context, parsed []importedGroup, groupHosts map[string][]string, dryRun bool, result *importResult) {
+	uid, uname := auditUser(c)
+	groupIDs := map[string]string{}
+	for _, pg := range parsed {
+		if err := validateGroupName(pg.Name); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		g, err := h.groups.GetByName(pg.Name)
+		if err != nil {
+			result.Errors = append(result.Errors, pg.Name+": "+err.Error())
 			continue
 		}
-		h.audit.Log(uid, uname, "create", "host", host.ID, "imported from inventory file", c.ClientIP())
-		result.Created = append(result.Created, ph.Name)
-		existingNames[ph.Name] = true
+		if dryRun {
+			if g == nil {
+				result.GroupsCreated = append(result.GroupsCreated, pg.Name)
+			} else {
+				result.GroupsUpdated = append(result.GroupsUpdated, pg.Name)
+			}
+			continue
+		}
+		if g == nil {
+			g, err = h.groups.Create(pg.Name, "", pg.Vars, groupHosts[pg.Name], nil)
+			if err != nil {
+				result.Errors = append(result.Errors, pg.Name+": "+err.Error())
+				continue
+			}
+			h.audit.Log(uid, uname, "create", "inventory-group", g.ID, "imported from inventory file", c.ClientIP())
+			result.GroupsCreated = append(result.GroupsCreated, pg.Name)
+		} else {
+			if err := h.groups.AddMembers(g.ID, groupHosts[pg.Name], nil); err != nil {
+				result.Errors = append(result.Errors, pg.Name+": "+err.Error())
+				continue
+			}
+			h.audit.Log(uid, uname, "update", "inventory-group", g.ID, "imported from inventory file", c.ClientIP())
+			result.GroupsUpdated = append(result.GroupsUpdated, pg.Name)
+		}
+		groupIDs[pg.Name] = g.ID
+	}
+	if dryRun {
+		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	all, err := h.groups.List()
+	if err != nil {
+		result.Errors = append(result.Errors, "load inventory groups: "+err.Error())
+		return
+	}
+	children := make(map[string][]string, len(all))
+	for _, g := range all {
+		children[g.ID] = g.ChildIDs
+	}
+	for _, pg := range parsed {
+		parentID, ok := groupIDs[pg.Name]
+		if !ok {
+			continue
+		}
+		var childIDs []string
+		for _, child := range pg.Children {
+			childID, ok := groupIDs[child]
+			if !ok {
+				continue
+			}
+			if childID == parentID || groupReaches(children, children[childID], parentID, map[string]bool{}) {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s:children: %s would make %s its own descendant", pg.Name, child, pg.Name))
+				continue
+			}
+			childIDs = append(childIDs, childID)
+			children[parentID] = append(children[parentID], childID)
+		}
+		if len(childIDs) == 0 {
+			continue
+		}
+		if err := h.groups.AddMembers(parentID, nil, childIDs); err != nil {
+			result.Errors = append(result.Errors, pg.Name+": "+err.Error())
+		}
+	}
 }
 
-// parseAnsibleINI parses an Ansible INI inventory file.
+// parseAnsibleINI parses an Ansible INI inventory file into hosts and the
+// groups they are listed under, in the order they first appear.
 //
 // Name resolution:
 //   - If a group has exactly one host and that host has no explicit alias
 //     (the line is a bare IP/hostname), the group name is used as the host name.
 //   - Otherwise the alias or bare address is used as-is.
 //
-// Vars:
-//   - [group:vars] entries are merged into every host in that group.
-//   - Inline host vars (key=value on the host line) take precedence.
+// Groups:
+//   - A host listed under several groups belongs to each of them.
+//   - [group:vars] entries become the group's vars; [group:children] entries
+//     its child groups.
+//   - all and ungrouped are Ansible's implicit groups and are not returned;
+//     [all:vars] and [ungrouped:vars] are merged into the hosts listed
+//     directly under them, with inline host vars taking precedence.
 //   - varsToSkip entries (e.g. ansible_connection, ssh key file paths) are dropped.
-func parseAnsibleINI(content []byte) []importedHost {
+func parseAnsibleINI(content []byte) ([]importedHost, []importedGroup) {
 	type groupData struct {
-		hostIndices []int
-		vars        map[string]string
+		hostIndices []int // hosts first listed under this group
+		vars        map[string]interface{}
+		children    []string
 	}
 
 	type rawHost struct {
-		name        string // alias or bare address
-		address     string
-		hasAlias    bool // true when name != address
-		inlineVars  map[string]string
-		groupName   string
+		name       string // alias or bare address
+		address    string
+		hasAlias   bool // true when name != address
+		inlineVars map[string]string
+		groups     []string // groups the host is listed under, first one first
 	}
 
 	var rawHosts []rawHost
-	seenNames := map[string]bool{}
+	hostIndex := map[string]int{}
 	groups := map[string]*groupData{}
+	var groupOrder []string
+	group := func(name string) *groupData {
+		g, ok := groups[name]
+		if !ok {
+			g = &groupData{vars: map[string]interface{}{}}
+			groups[name] = g
+			groupOrder = append(groupOrder, name)
+		}
+		return g
+	}
+	implicit := func(name string) bool { return name == "all" || name == "ungrouped" }
 
 	currentGroup := ""
 	currentSection := "" // "hosts" | "vars" | "children"
@@ -130,19 +347,14 @@ func parseAnsibleINI(content []byte) []importedHost {
 			case strings.HasSuffix(lower, ":vars"):
 				currentGroup = section[:len(section)-5]
 				currentSection = "vars"
-				if _, ok := groups[currentGroup]; !ok {
-					groups[currentGroup] = &groupData{vars: map[string]string{}}
-				}
 			case strings.HasSuffix(lower, ":children"):
-				currentGroup = ""
+				currentGroup = section[:len(section)-9]
 				currentSection = "children"
 			default:
 				currentGroup = section
 				currentSection = "hosts"
-				if _, ok := groups[currentGroup]; !ok {
-					groups[currentGroup] = &groupData{vars: map[string]string{}}
-				}
 			}
+			group(currentGroup)
 			continue
 		}
 
@@ -160,6 +372,17 @@ func parseAnsibleINI(content []byte) []importedHost {
 			continue
 		}
 
+		// Child group line.
+		if currentSection == "children" {
+			child := strings.Fields(line)[0]
+			g := groups[currentGroup]
+			if !implicit(child) && !containsString(g.children, child) {
+				g.children = append(g.children, child)
+				group(child)
+			}
+			continue
+		}
+
 		// Host line.
 		if currentSection == "hosts" {
 			tokens := tokenizeHostLine(line)
@@ -167,6 +390,15 @@ func parseAnsibleINI(content []byte) []importedHost {
 				continue
 			}
 
+			name := tokens[0]
+			if idx, ok := hostIndex[name]; ok {
+				rh := &rawHosts[idx]
+				if !containsString(rh.groups, currentGroup) {
+					rh.groups = append(rh.groups, currentGroup)
+				}
+				continue
+			}
+
 			inlineVars := make(map[string]string)
 			for _, tok := range tokens[1:] {
 				idx := strings.IndexByte(tok, '=')
@@ -188,55 +420,342 @@ func parseAnsibleINI(content []byte) []importedHost {
 				delete(inlineVars, "ansible_host")
 			}
 
-			name := tokens[0]
-			if seenNames[name] {
-				continue
-			}
-			seenNames[name] = true
-
 			idx := len(rawHosts)
+			hostIndex[name] = idx
 			rawHosts = append(rawHosts, rawHost{
 				name:       name,
 				address:    address,
 				hasAlias:   hasAlias,
 				inlineVars: inlineVars,
-				groupName:  currentGroup,
+				groups:     []string{currentGroup},
 			})
 			g := groups[currentGroup]
 			g.hostIndices = append(g.hostIndices, idx)
 		}
 	}
 
-	// Build final host list: apply group name and merge group vars.
+	// Build final host list: apply group name and merge implicit group vars.
 	hosts := make([]importedHost, 0, len(rawHosts))
 	for i := range rawHosts {
 		rh := &rawHosts[i]
-		g := groups[rh.groupName]
+		first := rh.groups[0]
+		g := groups[first]
 
 		// Use the group name as the host name when the group has a single host
 		// and the host has no explicit alias (bare IP or FQDN was the only token).
 		finalName := rh.name
-		if !rh.hasAlias && len(g.hostIndices) == 1 && rh.groupName != "" {
-			finalName = rh.groupName
+		if !rh.hasAlias && len(g.hostIndices) == 1 && !implicit(first) {
+			finalName = first
 		}
 
-		// Merge vars: inline takes precedence over group vars.
-		merged := make(map[string]string, len(g.vars)+len(rh.inlineVars))
-		for k, v := range g.vars {
-			merged[k] = v
+		vars := make(map[string]interface{}, len(rh.inlineVars))
+		var memberOf []string
+		for _, name := range rh.groups {
+			if implicit(name) {
+				for k, v := range groups[name].vars {
+					vars[k] = v
+				}
+				continue
+			}
+			memberOf = append(memberOf, name)
 		}
 		for k, v := range rh.inlineVars {
-			merged[k] = v
+			vars[k] = v
 		}
 
 		hosts = append(hosts, importedHost{
 			Name:    finalName,
 			Address: rh.address,
-			Vars:    merged,
+			Vars:    vars,
+			Groups:  memberOf,
 		})
 	}
 
-	return hosts
+	var outGroups []importedGroup
+	for _, name := range groupOrder {
+		if implicit(name) {
+			continue
+		}
+		g := groups[name]
+		outGroups = append(outGroups, importedGroup{Name: name, Vars: g.vars, Children: g.children})
+	}
+	return hosts, outGroups
+}
+
+// parseInventoryJSON parses the JSON printed by `ansible-inventory --list`
+// (also the format of dynamic inventory scripts) into hosts and groups:
+//
+//	{"_meta": {"hostvars": {"web1": {"ansible_host": "10.0.0.5"}}},
+//	 "all": {"children": ["web"]},
+//	 "web": {"hosts": ["web1"], "vars": {"http_port": 80}, "children": []}}
+//
+// A group may also be a plain list of host names. ansible_host becomes the
+// host's address; host and group vars keep their types. Hosts are returned
+// sorted by name. As in parseAnsibleINI, all and ungrouped are not returned
+// as groups, their vars are merged into their hosts (all's into every host)
+// and varsToSkip entries are dropped.
+func parseInventoryJSON(content []byte) ([]importedHost, []importedGroup, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, nil, fmt.Errorf("parse inventory JSON: %w", err)
+	}
+	var meta struct {
+		HostVars map[string]map[string]interface{} `json:"hostvars"`
+	}
+	if m, ok := raw["_meta"]; ok {
+		if err := json.Unmarshal(m, &meta); err != nil {
+			return nil, nil, fmt.Errorf("parse inventory JSON _meta: %w", err)
+		}
+	}
+
+	inv := newInventoryCollector()
+	for name, vars := range meta.HostVars {
+		inv.addHost(name, "", vars)
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		if name != "_meta" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var g struct {
+			Hosts    []string               `json:"hosts"`
+			Vars     map[string]interface{} `json:"vars"`
+			Children []string               `json:"children"`
+		}
+		if err := json.Unmarshal(raw[name], &g); err != nil {
+			if err := json.Unmarshal(raw[name], &g.Hosts); err != nil {
+				return nil, nil, fmt.Errorf("parse inventory JSON group %q: must be an object or a list of hosts", name)
+			}
+		}
+		inv.addGroup(name, g.Vars, g.Children)
+		for _, host := range g.Hosts {
+			inv.addHost(host, name, nil)
+		}
+	}
+	hosts, groups := inv.result()
+	return hosts, groups, nil
+}
+
+// parseInventoryYAML parses an Ansible YAML inventory into hosts and groups:
+//
+//	all:
+//	  hosts:
+//	    web1: {ansible_host: 10.0.0.5}
+//	  children:
+//	    web:
+//	      hosts: {web1: }
+//	      vars: {http_port: 80}
+//	      children: {canary: }
+//
+// A host may be listed under several groups; its vars are merged in the
+// order they appear. Vars are converted as in parseInventoryJSON, and all and
+// ungrouped are handled as in parseAnsibleINI.
+func parseInventoryYAML(content []byte) ([]importedHost, []importedGroup, error) {
+	var root map[string]*yamlInventoryGroup
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, nil, fmt.Errorf("parse inventory YAML: %w", err)
+	}
+	inv := newInventoryCollector()
+	var walk func(name string, g *yamlInventoryGroup)
+	walk = func(name string, g *yamlInventoryGroup) {
+		if g == nil {
+			g = &yamlInventoryGroup{}
+		}
+		children := sortedNames(g.Children)
+		inv.addGroup(name, g.Vars, children)
+		for _, host := range sortedNames(g.Hosts) {
+			inv.addHost(host, name, g.Hosts[host])
+		}
+		for _, child := range children {
+			walk(child, g.Children[child])
+		}
+	}
+	for _, name := range sortedNames(root) {
+		walk(name, root[name])
+	}
+	hosts, groups := inv.result()
+	return hosts, groups, nil
+}
+
+// yamlInventoryGroup is a group of an Ansible YAML inventory, as parsed on
+// import and as rendered for runs by renderYAMLInventory.
+type yamlInventoryGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts,omitempty"`
+	Vars     map[string]interface{}            `yaml:"vars,omitempty"`
+	Children map[string]*yamlInventoryGroup    `yaml:"children,omitempty"`
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inventoryCollector gathers the hosts and groups of a structured (JSON or
+// YAML) inventory, in the order they are first seen.
+type inventoryCollector struct {
+	hostOrder  []string
+	hostVars   map[string]map[string]interface{}
+	hostGroups map[string][]string
+	groupOrder []string
+	groups     map[string]*importedGroup
+	// vars of the implicit groups, merged into their hosts by result
+	allVars       map[string]interface{}
+	ungroupedVars map[string]interface{}
+}
+
+func newInventoryCollector() *inventoryCollector {
+	return &inventoryCollector{
+		hostVars:      map[string]map[string]interface{}{},
+		hostGroups:    map[string][]string{},
+		groups:        map[string]*importedGroup{},
+		allVars:       map[string]interface{}{},
+		ungroupedVars: map[string]interface{}{},
+	}
+}
+
+func isImplicitGroup(name string) bool { return name == "all" || name == "ungrouped" }
+
+// addHost records host, listed under group ("" for none), with vars that
+// override the ones seen for it before.
+func (inv *inventoryCollector) addHost(host, group string, vars map[string]interface{}) {
+	if _, ok := inv.hostVars[host]; !ok {
+		inv.hostOrder = append(inv.hostOrder, host)
+		inv.hostVars[host] = map[string]interface{}{}
+	}
+	for k, v := range typedVars(vars) {
+		inv.hostVars[host][k] = v
+	}
+	if group == "ungrouped" && !containsString(inv.hostGroups[host], group) {
+		// Tracked so ungrouped's vars reach the host; dropped by result.
+		inv.hostGroups[host] = append(inv.hostGroups[host], group)
+	}
+	if group != "" && !isImplicitGroup(group) && !containsString(inv.hostGroups[host], group) {
+		inv.hostGroups[host] = append(inv.hostGroups[host], group)
+	}
+}
+
+// addGroup records a group's vars and child groups, merging them into what
+// an earlier mention of the group recorded.
+func (inv *inventoryCollector) addGroup(name string, vars map[string]interface{}, children []string) {
+	switch name {
+	case "all":
+		for k, v := range typedVars(vars) {
+			inv.allVars[k] = v
+		}
+		return
+	case "ungrouped":
+		for k, v := range typedVars(vars) {
+			inv.ungroupedVars[k] = v
+		}
+		return
+	}
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &importedGroup{Name: name, Vars: map[string]interface{}{}}
+		inv.groups[name] = g
+		inv.groupOrder = append(inv.groupOrder, name)
+	}
+	for k, v := range typedVars(vars) {
+		g.Vars[k] = v
+	}
+	for _, child := range children {
+		if !isImplicitGroup(child) && !containsString(g.Children, child) {
+			g.Children = append(g.Children, child)
+		}
+	}
+}
+
+// result returns the hosts sorted by name, with ansible_host as their
+// address and the implicit groups' vars merged in, and the groups in the
+// order they were first seen.
+func (inv *inventoryCollector) result() ([]importedHost, []importedGroup) {
+	hosts := make([]importedHost, 0, len(inv.hostOrder))
+	for _, name := range inv.hostOrder {
+		vars := make(map[string]interface{}, len(inv.allVars)+len(inv.hostVars[name]))
+		for k, v := range inv.allVars {
+			vars[k] = v
+		}
+		var memberOf []string
+		for _, g := range inv.hostGroups[name] {
+			if g == "ungrouped" {
+				for k, v := range inv.ungroupedVars {
+					vars[k] = v
+				}
+				continue
+			}
+			memberOf = append(memberOf, g)
+		}
+		for k, v := range inv.hostVars[name] {
+			vars[k] = v
+		}
+		address := name
+		if ah, ok := vars["ansible_host"].(string); ok && ah != "" {
+			address = ah
+		}
+		delete(vars, "ansible_host")
+		hosts = append(hosts, importedHost{Name: name, Address: address, Vars: vars, Groups: memberOf})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	groups := make([]importedGroup, 0, len(inv.groupOrder))
+	for _, name := range inv.groupOrder {
+		groups = append(groups, *inv.groups[name])
+	}
+	return hosts, groups
+}
+
+// typedVars copies JSON or YAML inventory vars as host or group vars, which
+// keep their types, dropping varsToSkip entries. YAML maps with non-string keys get
+// their keys formatted as strings so the vars can be stored as JSON.
+func typedVars(in map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		if !varsToSkip[k] {
+			out[k] = jsonValue(v)
+		}
+	}
+	return out
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = jsonValue(e)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = jsonValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // tokenizeHostLine splits a host line respecting single- and double-quoted values.
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryGroupsHandler struct {
+	groups *store.InventoryGroupStore
+	hosts  *store.HostStore
+	audit  *store.AuditStore
+}
+
+func newInventoryGroupsHandler(groups *store.InventoryGroupStore, hosts *store.HostStore, audit *store.AuditStore) *InventoryGroupsHandler {
+	return &InventoryGroupsHandler{groups: groups, hosts: hosts, audit: audit}
+}
+
+type inventoryGroupRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Vars        map[string]interface{} `json:"vars"`
+	HostIDs     []string               `json:"host_ids"`
+	ChildIDs    []string               `json:"child_ids"`
+}
+
+func (h *InventoryGroupsHandler) List(c *gin.Context) {
+	list, err := h.groups.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if list == nil {
+		list = []*models.InventoryGroup{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *InventoryGroupsHandler) Get(c *gin.Context) {
+	g, err := h.groups.Get(c.Param("id"))
+	if err != nil || g == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "inventory group not found"})
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+func (h *InventoryGroupsHandler) Create(c *gin.Context) {
+	var req inventoryGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate("", req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	g, err := h.groups.Create(req.Name, req.Description, req.Vars, req.HostIDs, req.ChildIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "create", "inventory-group", g.ID, "", c.ClientIP())
+	c.JSON(http.StatusCreated, g)
+}
+
+func (h *InventoryGroupsHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+	var req inventoryGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validate(id, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	g, err := h.groups.Update(id, req.Name, req.Description, req.Vars, req.HostIDs, req.ChildIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if g == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "inventory group not found"})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "update", "inventory-group", id, "", c.ClientIP())
+	c.JSON(http.StatusOK, g)
+}
+
+func (h *InventoryGroupsHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.groups.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "delete", "inventory-group", id, "", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// validate checks a group's name, that its hosts and child groups exist, and
+// that the child groups would not make group id ("" for a new group) its own
+// descendant.
+func (h *InventoryGroupsHandler) validate(id string, req inventoryGroupRequest) error {
+	if err := validateGroupName(req.Name); err != nil {
+		return err
+	}
+	if existing, err := h.groups.GetByName(req.Name); err != nil {
+		return err
+	} else if existing != nil && existing.ID != id {
+		return fmt.Errorf("an inventory group named %q already exists", req.Name)
+	}
+	hosts, err := h.hosts.List()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		known[host.ID] = true
+	}
+	for _, hid := range req.HostIDs {
+		if !known[hid] {
+			return fmt.Errorf("host %s not found", hid)
+		}
+	}
+	groups, err := h.groups.List()
+	if err != nil {
+		return err
+	}
+	children := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		children[g.ID] = g.ChildIDs
+	}
+	for _, cid := range req.ChildIDs {
+		if _, ok := children[cid]; !ok {
+			return fmt.Errorf("child group %s not found", cid)
+		}
+	}
+	if id == "" {
+		return nil
+	}
+	children[id] = req.ChildIDs
+	if groupReaches(children, req.ChildIDs, id, map[string]bool{}) {
+		return fmt.Errorf("a group cannot be its own child or descendant")
+	}
+	return nil
+}
+
+// groupReaches reports whether target is one of from or their descendants.
+func groupReaches(children map[string][]string, from []string, target string, seen map[string]bool) bool {
+	for _, id := range from {
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if groupReaches(children, children[id], target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+var groupNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateGroupName accepts names Ansible uses as group names without
+// rewriting them: letters, digits and underscores, not starting with a digit.
+// all and ungrouped are Ansible's implicit groups.
+func validateGroupName(name string) error {
+	if !groupNameRe.MatchString(name) {
+		return fmt.Errorf("group name %q must be letters, digits and underscores, not starting with a digit", name)
+	}
+	if name == "all" || name == "ungrouped" {
+		return fmt.Errorf("group name %q is reserved by Ansible", name)
+	}
+	return nil
+}
+
+// buildGroupTreeInventory creates a YAML inventory for inventory group root
+// and its descendants: each group under all.children with its hosts, group
+// vars and child groups. Each host's ansible_host and host vars are written
+// where it is first listed only. It returns the hosts the inventory holds.
+func buildGroupTreeInventory(root *models.InventoryGroup, groups []*models.InventoryGroup, hosts []*models.Host) (string, []*models.Host) {
+	groupByID := make(map[string]*models.InventoryGroup, len(groups))
+	for _, g := range groups {
+		groupByID[g.ID] = g
+	}
+	hostByID := make(map[string]*models.Host, len(hosts))
+	for _, host := range hosts {
+		hostByID[host.ID] = host
+	}
+
+	all := &yamlInventoryGroup{Children: map[string]*yamlInventoryGroup{}}
+	var included []*models.Host
+	written := map[string]bool{}
+	seen := map[string]bool{root.ID: true}
+	queue := []*models.InventoryGroup{root}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+
+		yg := &yamlInventoryGroup{}
+		for _, hid := range g.HostIDs {
+			host := hostByID[hid]
+			if host == nil {
+				continue
+			}
+			if yg.Hosts == nil {
+				yg.Hosts = map[string]map[string]interface{}{}
+			}
+			if written[hid] {
+				yg.Hosts[host.Name] = map[string]interface{}{}
+				continue
+			}
+			written[hid] = true
+			included = append(included, host)
+			yg.Hosts[host.Name] = inventoryHostVars(host.Name, host.Address, host.Vars)
+		}
+		if len(g.Vars) > 0 {
+			yg.Vars = g.Vars
+		}
+		for _, cid := range g.ChildIDs {
+			child := groupByID[cid]
+			if child == nil {
+				continue
+			}
+			if yg.Children == nil {
+				yg.Children = map[string]*yamlInventoryGroup{}
+			}
+			yg.Children[child.Name] = &yamlInventoryGroup{}
+			if !seen[cid] {
+				seen[cid] = true
+				queue = append(queue, child)
+			}
+		}
+		all.Children[g.Name] = yg
+	}
+	return renderYAMLInventory(all), included
+}
@@ -0,0 +1,521 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/runner"
+	"github.com/brettjrea/ansible-frontend/internal/scheduler"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// inventorySyncTimeout bounds a whole sync: cloning, running
+// ansible-inventory or fetching the endpoint, and writing hosts.
+const inventorySyncTimeout = 5 * time.Minute
+
+// InventorySourcesHandler manages inventory sources and syncs their hosts
+// into the Hosts table.
+type InventorySourcesHandler struct {
+	sources   *store.InventorySourceStore
+	hosts     *store.HostStore
+	playbooks *store.PlaybookStore
+	servers   *store.ServerStore
+	audit     *store.AuditStore
+	sched     *scheduler.Scheduler
+}
+
+func NewInventorySourcesHandler(sources *store.InventorySourceStore, hosts *store.HostStore, playbooks *store.PlaybookStore, servers *store.ServerStore, audit *store.AuditStore, sched *scheduler.Scheduler) *InventorySourcesHandler {
+	return &InventorySourcesHandler{sources: sources, hosts: hosts, playbooks: playbooks, servers: servers, audit: audit, sched: sched}
+}
+
+type inventorySourceRequest struct {
+	Name          string `json:"name" binding:"required"`
+	Description   string `json:"description"`
+	Type          string `json:"type" binding:"required"`
+	PlaybookID    string `json:"playbook_id"`
+	Path          string `json:"path"`
+	ServerID      string `json:"server_id"`
+	URL           string `json:"url"`
+	Token         string `json:"token"` // empty keeps the stored token on update
+	ScheduleCron  string `json:"schedule_cron"`
+	DeleteMissing bool   `json:"delete_missing"`
+}
+
+// inventorySourceResponse wraps a source and adds the computed next_sync_at field.
+type inventorySourceResponse struct {
+	*models.InventorySource
+	NextSyncAt *time.Time `json:"next_sync_at"`
+}
+
+func scheduleKey(sourceID string) string { return "inventory-source:" + sourceID }
+
+func (h *InventorySourcesHandler) withNextSync(src *models.InventorySource) inventorySourceResponse {
+	var next *time.Time
+	if h.sched != nil {
+		next = h.sched.NextRunAt(scheduleKey(src.ID))
+	}
+	return inventorySourceResponse{InventorySource: src, NextSyncAt: next}
+}
+
+// ScheduleAll settles syncs the last process left running and registers the
+// schedule of every source that has one. Called once at startup.
+func (h *InventorySourcesHandler) ScheduleAll() error {
+	if err := h.sources.FailRunningSyncs(); err != nil {
+		return err
+	}
+	list, err := h.sources.List()
+	if err != nil {
+		return err
+	}
+	for _, src := range list {
+		h.schedule(src)
+	}
+	return nil
+}
+
+func (h *InventorySourcesHandler) schedule(src *models.InventorySource) {
+	if h.sched == nil {
+		return
+	}
+	id := src.ID
+	h.sched.UpsertFunc(scheduleKey(id), src.ScheduleCron, func() {
+		src, err := h.sources.Get(id)
+		if err != nil || src == nil {
+			return
+		}
+		if _, err := h.sync(src, "schedule", "", "scheduler", ""); err != nil {
+			log.Printf("[inventory] scheduled sync of %q: %v", src.Name, err)
+		}
+	})
+}
+
+func (h *InventorySourcesHandler) List(c *gin.Context) {
+	list, err := h.sources.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]inventorySourceResponse, 0, len(list))
+	for _, src := range list {
+		resp = append(resp, h.withNextSync(src))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *InventorySourcesHandler) Get(c *gin.Context) {
+	src, err := h.sources.Get(c.Param("id"))
+	if err != nil || src == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "inventory source not found"})
+		return
+	}
+	c.JSON(http.StatusOK, h.withNextSync(src))
+}
+
+func (h *InventorySourcesHandler) Create(c *gin.Context) {
+	var req inventorySourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	playbookID, serverID, err := h.validate("", &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	src, err := h.sources.Create(req.Name, req.Description, req.Type, playbookID, req.Path, serverID, req.URL, req.Token, req.ScheduleCron, req.DeleteMissing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.schedule(src)
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "create", "inventory-source", src.ID, "", c.ClientIP())
+	c.JSON(http.StatusCreated, h.withNextSync(src))
+}
+
+func (h *InventorySourcesHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+	var req inventorySourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	playbookID, serverID, err := h.validate(id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	src, err := h.sources.Update(id, req.Name, req.Description, req.Type, playbookID, req.Path, serverID, req.URL, req.Token, req.ScheduleCron, req.DeleteMissing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if src == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "inventory source not found"})
+		return
+	}
+	h.schedule(src)
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "update", "inventory-source", id, "", c.ClientIP())
+	c.JSON(http.StatusOK, h.withNextSync(src))
+}
+
+func (h *InventorySourcesHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.sources.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if h.sched != nil {
+		h.sched.Remove(scheduleKey(id))
+	}
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "delete", "inventory-source", id, "", c.ClientIP())
+	c.Status(http.StatusNoContent)
+}
+
+// Sync syncs a source now and returns the recorded sync, which may have
+// failed. It answers 409 when the source is already syncing.
+func (h *InventorySourcesHandler) Sync(c *gin.Context) {
+	src, err := h.sources.Get(c.Param("id"))
+	if err != nil || src == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "inventory source not found"})
+		return
+	}
+	uid, uname := auditUser(c)
+	sync, err := h.sync(src, "manual", uid, uname, c.ClientIP())
+	if sync == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sync)
+}
+
+// Syncs returns a source's sync history, newest first (?limit=, default 20).
+func (h *InventorySourcesHandler) Syncs(c *gin.Context) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+	list, err := h.sources.ListSyncs(c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if list == nil {
+		list = []*models.InventorySync{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// validate checks a source for its type, clearing the fields its type does
+// not use, and returns its playbook source and job runner as nullable IDs.
+func (h *InventorySourcesHandler) validate(id string, req *inventorySourceRequest) (playbookID, serverID *string, err error) {
+	if existing, err := h.sources.GetByName(req.Name); err != nil {
+		return nil, nil, err
+	} else if existing != nil && existing.ID != id {
+		return nil, nil, fmt.Errorf("an inventory source named %q already exists", req.Name)
+	}
+	if err := scheduler.ValidateCron(req.ScheduleCron); err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	req.Path = strings.TrimSpace(req.Path)
+	switch req.Type {
+	case "git":
+		req.ServerID, req.URL, req.Token = "", "", ""
+		if req.PlaybookID == "" {
+			return nil, nil, fmt.Errorf("git sources need a playbook source")
+		}
+		if pb, err := h.playbooks.Get(req.PlaybookID); err != nil || pb == nil {
+			return nil, nil, fmt.Errorf("playbook source %s not found", req.PlaybookID)
+		}
+		clean := path.Clean("/" + req.Path)
+		if req.Path == "" || clean == "/" {
+			return nil, nil, fmt.Errorf("git sources need the path of the inventory file in the repository")
+		}
+		req.Path = strings.TrimPrefix(clean, "/")
+		return &req.PlaybookID, nil, nil
+	case "runner":
+		req.PlaybookID, req.URL, req.Token = "", "", ""
+		if req.ServerID == "" {
+			return nil, nil, fmt.Errorf("runner sources need a job runner")
+		}
+		sv, err := h.servers.Get(req.ServerID)
+		if err != nil || sv == nil {
+			return nil, nil, fmt.Errorf("job runner %s not found", req.ServerID)
+		}
+		if sv.RunnerType == runner.KindKubernetes {
+			return nil, nil, fmt.Errorf("job runner %q is a Kubernetes runner; runner sources need an SSH or local runner", sv.Name)
+		}
+		if req.Path == "" {
+			return nil, nil, fmt.Errorf("runner sources need the inventory path ansible-inventory reads on the runner")
+		}
+		return nil, &req.ServerID, nil
+	case "http":
+		req.PlaybookID, req.ServerID, req.Path = "", "", ""
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, nil, fmt.Errorf("http sources need an http:// or https:// URL")
+		}
+		return nil, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("type must be git, runner or http")
+	}
+}
+
+// sync runs one sync of src (see applySync), records its outcome with
+// FinishSync and audits it. It returns a nil sync only when the source is
+// already syncing; any other failure is recorded in the returned sync.
+func (h *InventorySourcesHandler) sync(src *models.InventorySource, trigger, uid, uname, ip string) (*models.InventorySync, error) {
+	sync, err := h.sources.StartSync(src.ID, trigger)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), inventorySyncTimeout)
+	defer cancel()
+
+	err = h.applySync(ctx, src, sync)
+	sync.Status = "success"
+	if err != nil {
+		sync.Status = "failed"
+		sync.Message = err.Error()
+	} else {
+		sync.Message = fmt.Sprintf("%d created, %d updated, %d deleted, %d skipped",
+			len(sync.Created), len(sync.Updated), len(sync.Deleted), len(sync.Skipped))
+	}
+	if err := h.sources.FinishSync(sync); err != nil {
+		log.Printf("[inventory] save sync of %q: %v", src.Name, err)
+	}
+	h.audit.Log(uid, uname, "sync", "inventory-source", src.ID, fmt.Sprintf("%s sync %s: %s", trigger, sync.Status, sync.Message), ip)
+	return sync, err
+}
+
+// applySync creates the hosts src lists and updates the address and vars of
+// the ones it synced before, keeping their description, SSH cert and labels.
+// Hosts added by hand or synced by another source are skipped, so a sync
+// never takes over, or with delete_missing later deletes, a host it does not
+// own. With delete_missing, hosts src synced before that it no longer lists
+// are deleted, unless it lists no hosts at all, so a broken endpoint cannot
+// empty the inventory.
+func (h *InventorySourcesHandler) applySync(ctx context.Context, src *models.InventorySource, sync *models.InventorySync) error {
+	listed, err := h.fetch(ctx, src)
+	if err != nil {
+		return err
+	}
+	existing, err := h.hosts.List()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*models.Host, len(existing))
+	for _, host := range existing {
+		byName[host.Name] = host
+	}
+
+	seen := make(map[string]bool, len(listed))
+	for _, lh := range listed {
+		seen[lh.Name] = true
+		host := byName[lh.Name]
+		switch {
+		case host == nil:
+			created, err := h.hosts.Create(lh.Name, lh.Address, "", nil, lh.Vars, nil)
+			if err != nil {
+				return fmt.Errorf("create host %s: %w", lh.Name, err)
+			}
+			if err := h.hosts.SetSource(created.ID, &src.ID); err != nil {
+				return err
+			}
+			sync.Created = append(sync.Created, lh.Name)
+		case host.SourceID == nil:
+			sync.Skipped = append(sync.Skipped, lh.Name+": exists, added by hand")
+		case *host.SourceID != src.ID:
+			sync.Skipped = append(sync.Skipped, lh.Name+": synced by another inventory source")
+		case host.Address != lh.Address || !sameVars(host.Vars, lh.Vars):
+			if _, err := h.hosts.Update(host.ID, host.Name, lh.Address, host.Description, host.SSHCertID, lh.Vars, host.Labels); err != nil {
+				return fmt.Errorf("update host %s: %w", lh.Name, err)
+			}
+			if err := h.hosts.SetSource(host.ID, &src.ID); err != nil {
+				return err
+			}
+			sync.Updated = append(sync.Updated, lh.Name)
+		}
+	}
+
+	if !src.DeleteMissing {
+		return nil
+	}
+	var stale []*models.Host
+	for _, host := range existing {
+		if host.SourceID != nil && *host.SourceID == src.ID && !seen[host.Name] {
+			stale = append(stale, host)
+		}
+	}
+	if len(listed) == 0 && len(stale) > 0 {
+		return fmt.Errorf("the source listed no hosts; not deleting the %d host(s) it synced before", len(stale))
+	}
+	for _, host := range stale {
+		if err := h.hosts.Delete(host.ID); err != nil {
+			return fmt.Errorf("delete host %s: %w", host.Name, err)
+		}
+		sync.Deleted = append(sync.Deleted, host.Name)
+	}
+	return nil
+}
+
+// fetch reads a source's inventory and parses its hosts. Git files may be
+// INI, YAML or ansible-inventory JSON; runners and HTTP endpoints return JSON.
+func (h *InventorySourcesHandler) fetch(ctx context.Context, src *models.InventorySource) ([]importedHost, error) {
+	var content []byte
+	var err error
+	switch src.Type {
+	case "git":
+		content, err = h.fetchGit(ctx, src)
+	case "runner":
+		content, err = h.fetchRunner(ctx, src)
+	case "http":
+		content, err = h.fetchHTTP(ctx, src)
+	default:
+		err = fmt.Errorf("unknown source type %q", src.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	format := "json"
+	if src.Type == "git" {
+		format = detectInventoryFormat(src.Path, content)
+	}
+	var hosts []importedHost
+	switch format {
+	case "ini":
+		hosts, _ = parseAnsibleINI(content)
+	case "yaml":
+		hosts, _, err = parseInventoryYAML(content)
+	default:
+		hosts, _, err = parseInventoryJSON(content)
+	}
+	return hosts, err
+}
+
+func (h *InventorySourcesHandler) fetchGit(ctx context.Context, src *models.InventorySource) ([]byte, error) {
+	if src.PlaybookID == nil {
+		return nil, fmt.Errorf("the source's playbook source was deleted")
+	}
+	pb, err := h.playbooks.Get(*src.PlaybookID)
+	if err != nil || pb == nil {
+		return nil, fmt.Errorf("the source's playbook source was deleted")
+	}
+	dir, err := os.MkdirTemp("", "inventory-source-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	repoDir := filepath.Join(dir, "repo")
+	if err := cloneShallow(ctx, pb, repoDir); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(path.Clean("/"+src.Path))))
+	if err != nil {
+		return nil, fmt.Errorf("read %s from %s: %w", src.Path, pb.Name, err)
+	}
+	return content, nil
+}
+
+func (h *InventorySourcesHandler) fetchRunner(ctx context.Context, src *models.InventorySource) ([]byte, error) {
+	if src.ServerID == nil {
+		return nil, fmt.Errorf("the source's job runner was deleted")
+	}
+	sv, err := h.servers.Get(*src.ServerID)
+	if err != nil || sv == nil {
+		return nil, fmt.Errorf("the source's job runner was deleted")
+	}
+	switch sv.RunnerType {
+	case runner.KindKubernetes:
+		return nil, fmt.Errorf("job runner %q is a Kubernetes runner; runner sources need an SSH or local runner", sv.Name)
+	case runner.KindLocal:
+		return runner.LocalInventoryList(ctx, src.Path, sv.PreCommand)
+	}
+	chain, err := serverChain(h.servers, sv)
+	if err != nil {
+		return nil, err
+	}
+	client, err := runner.Connect(serverHops(chain)...)
+	if err != nil {
+		return nil, fmt.Errorf("SSH connect failed: %w", err)
+	}
+	defer client.Close()
+	out, err := client.Output(runner.InventoryListCommand(src.Path, sv.PreCommand))
+	if err != nil {
+		return nil, fmt.Errorf("ansible-inventory on %s: %w", sv.Name, err)
+	}
+	return out, nil
+}
+
+// maxInventoryResponse caps the body read from an HTTP source.
+const maxInventoryResponse = 10 << 20
+
+func (h *InventorySourcesHandler) fetchHTTP(ctx context.Context, src *models.InventorySource) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if src.HasToken {
+		token, err := h.sources.GetDecryptedToken(src.ID)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxInventoryResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("GET %s: %s", src.URL, resp.Status)
+	}
+	if len(body) > maxInventoryResponse {
+		return nil, fmt.Errorf("GET %s: response is larger than %d MB", src.URL, maxInventoryResponse>>20)
+	}
+	return body, nil
+}
+
+// sameVars reports whether two sets of host vars hold the same values,
+// comparing their JSON so that, say, a YAML int matches the float64 the
+// stored vars decode to.
+func sameVars(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) == 0 {
+		return true
+	}
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	return err == nil && bytes.Equal(ja, jb)
+}
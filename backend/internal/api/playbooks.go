@@ -53,6 +53,9 @@ type playbookBody struct {
 	RepoURL     string `json:"repo_url"`
 	Branch      string `json:"branch"`
 	Token       string `json:"token"`
+	// GalaxyToken is write-only; blank on update keeps the stored token.
+	GalaxyServerURL string `json:"galaxy_server_url"`
+	GalaxyToken     string `json:"galaxy_token"`
 }
 
 func (h *PlaybooksHandler) Create(c *gin.Context) {
@@ -69,7 +72,7 @@ func (h *PlaybooksHandler) Create(c *gin.Context) {
 		body.Branch = "main"
 	}
 
-	p, err := h.playbooks.Create(body.Name, body.Description, body.RepoURL, body.Branch, body.Token)
+	p, err := h.playbooks.Create(body.Name, body.Description, body.RepoURL, body.Branch, body.Token, body.GalaxyServerURL, body.GalaxyToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -93,7 +96,7 @@ func (h *PlaybooksHandler) Update(c *gin.Context) {
 		body.Branch = "main"
 	}
 
-	p, err := h.playbooks.Update(c.Param("id"), body.Name, body.Description, body.RepoURL, body.Branch, body.Token)
+	p, err := h.playbooks.Update(c.Param("id"), body.Name, body.Description, body.RepoURL, body.Branch, body.Token, body.GalaxyServerURL, body.GalaxyToken)
 	if err != nil || p == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
@@ -12,7 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, formImageDir string, jwtSecret string, runsH *RunsHandler, sched *scheduler.Scheduler, sshCertsH *SSHCertsHandler, hostsH *HostsHandler, eeH *EEEditorHandler) *gin.Engine {
+func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, formImageDir string, jwtSecret string, runsH *RunsHandler, sched *scheduler.Scheduler, sshCertsH *SSHCertsHandler, hostsH *HostsHandler, inventorySourcesH *InventorySourcesHandler, hostPingH *HostPingHandler, eeH *EEEditorHandler) *gin.Engine {
 	r := gin.Default()
 	// Disable automatic redirects that generate http:// Location headers when
 	// the app runs behind an SSL-terminating reverse proxy (e.g. Nginx Proxy Manager).
@@ -47,7 +47,9 @@ func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, for
 	settingsH := newSettingsHandler(db.Settings(), db.Users())
 	serversH := newServersHandler(db.Servers(), auditStore)
 	serverGroupsH := newServerGroupsHandler(db.ServerGroups(), auditStore)
-	playbooksH := newPlaybooksHandler(db.Playbooks(), auditStore)
+	inventoryGroupsH := newInventoryGroupsHandler(db.InventoryGroups(), db.Hosts(), auditStore)
+	hostFactsH := newHostFactsHandler(db.Hosts(), db.HostFacts(), db.Servers(), db.SSHCerts(jwtSecret), auditStore)
+	playbooksH := newPlaybooksHandler(db.Playbooks(jwtSecret), auditStore)
 	formsH := newFormsHandler(db.Forms(), auditStore, formImageDir, sched)
 	vaultsH := newVaultsHandler(vaultStore, auditStore, vaultUploadDir)
 
@@ -78,9 +80,14 @@ func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, for
 
 			// Hosts (Ansible inventory targets)
 			protected.GET("/hosts", hostsH.List)
+			protected.GET("/hosts/export", hostsH.Export)
 			protected.GET("/hosts/:id", hostsH.Get)
+			protected.GET("/hosts/:id/facts", hostFactsH.Get)
+			protected.POST("/hosts/:id/facts/gather", auth.RequireAdmin, hostFactsH.Gather)
 			protected.POST("/hosts", auth.RequireAdmin, hostsH.Create)
 			protected.POST("/hosts/import", auth.RequireAdmin, hostsH.Import)
+			protected.POST("/hosts/ping", auth.RequireAdmin, hostPingH.PingMany)
+			protected.POST("/hosts/:id/ping", auth.RequireAdmin, hostPingH.Ping)
 			protected.PUT("/hosts/:id", auth.RequireAdmin, hostsH.Update)
 			protected.DELETE("/hosts/:id", auth.RequireAdmin, hostsH.Delete)
 
@@ -101,6 +108,22 @@ func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, for
 			protected.GET("/server-groups/:id/members", serverGroupsH.GetMembers)
 			protected.PUT("/server-groups/:id/members", auth.RequireAdmin, serverGroupsH.SetMembers)
 
+			// Inventory groups (Ansible groups of hosts with group vars and children)
+			protected.GET("/inventory-groups", inventoryGroupsH.List)
+			protected.GET("/inventory-groups/:id", inventoryGroupsH.Get)
+			protected.POST("/inventory-groups", auth.RequireAdmin, inventoryGroupsH.Create)
+			protected.PUT("/inventory-groups/:id", auth.RequireAdmin, inventoryGroupsH.Update)
+			protected.DELETE("/inventory-groups/:id", auth.RequireAdmin, inventoryGroupsH.Delete)
+
+			// Inventory sources (dynamic inventories synced into hosts)
+			protected.GET("/inventory-sources", inventorySourcesH.List)
+			protected.GET("/inventory-sources/:id", inventorySourcesH.Get)
+			protected.POST("/inventory-sources", auth.RequireAdmin, inventorySourcesH.Create)
+			protected.PUT("/inventory-sources/:id", auth.RequireAdmin, inventorySourcesH.Update)
+			protected.DELETE("/inventory-sources/:id", auth.RequireAdmin, inventorySourcesH.Delete)
+			protected.POST("/inventory-sources/:id/sync", auth.RequireAdmin, inventorySourcesH.Sync)
+			protected.GET("/inventory-sources/:id/syncs", inventorySourcesH.Syncs)
+
 			// Playbook Sources (git repos)
 			protected.GET("/playbooks", playbooksH.List)
 			protected.GET("/playbooks/:id", playbooksH.Get)
@@ -152,8 +175,15 @@ func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, for
 			// Runs
 			protected.GET("/runs", runsH.List)
 			protected.GET("/runs/:id", runsH.Get)
+			protected.GET("/runs/:id/events", runsH.Events)
+			protected.GET("/runs/:id/hosts", runsH.Hosts)
 			protected.POST("/runs", runsH.Create)
 			protected.POST("/runs/:id/cancel", runsH.Cancel)
+			protected.POST("/runs/:id/relaunch", runsH.Relaunch)
+			protected.GET("/queue", runsH.Queue)
+			protected.GET("/batches/:id", runsH.GetBatch)
+			protected.POST("/batches/:id/approve", auth.RequireEditor, runsH.ApproveBatch)
+			protected.POST("/batches/:id/cancel", runsH.CancelBatch)
 
 			// Settings (admin only)
 			protected.GET("/settings/app", auth.RequireAdmin, settingsH.GetApp)
@@ -163,6 +193,8 @@ func NewRouter(db *store.DB, jwtSvc *auth.JWTService, vaultUploadDir string, for
 			protected.POST("/settings/email/test", auth.RequireAdmin, settingsH.TestEmail)
 			protected.GET("/settings/github", auth.RequireAdmin, settingsH.GetGitHub)
 			protected.PUT("/settings/github", auth.RequireAdmin, settingsH.UpdateGitHub)
+			protected.GET("/settings/host-ping", auth.RequireAdmin, hostPingH.GetSettings)
+			protected.PUT("/settings/host-ping", auth.RequireAdmin, hostPingH.UpdateSettings)
 
 			// Audit log (admin only)
 			protected.GET("/audit", auth.RequireAdmin, auditH.List)
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/runner"
+	"github.com/brettjrea/ansible-frontend/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// runEventRecorder stores the events callback plugin's output for one run,
+// mapping Ansible's play and task UUIDs to the rows created for them.
+type runEventRecorder struct {
+	events *store.RunEventStore
+	runID  string
+	playID string            // current play's row ID
+	tasks  map[string]string // Ansible task UUID -> run_tasks.id
+}
+
+func newRunEventRecorder(events *store.RunEventStore, runID string) *runEventRecorder {
+	return &runEventRecorder{events: events, runID: runID, tasks: map[string]string{}}
+}
+
+func (r *runEventRecorder) record(ev *runner.Event) {
+	at := eventTime(ev.Time)
+	switch ev.Event {
+	case runner.EventPlayStart:
+		p, err := r.events.AddPlay(r.runID, ev.Name, at)
+		if err != nil {
+			log.Printf("[runs] record play for run %s: %v", r.runID, err)
+			return
+		}
+		r.playID = p.ID
+	case runner.EventTaskStart:
+		if r.playID == "" {
+			return
+		}
+		t, err := r.events.AddTask(r.runID, r.playID, ev.Name, ev.Action, ev.Handler, at)
+		if err != nil {
+			log.Printf("[runs] record task for run %s: %v", r.runID, err)
+			return
+		}
+		r.tasks[ev.TaskID] = t.ID
+	case runner.EventHostResult:
+		taskID, ok := r.tasks[ev.TaskID]
+		if !ok {
+			return
+		}
+		err := r.events.AddHostResult(&models.RunHostResult{
+			RunID:        r.runID,
+			TaskID:       taskID,
+			Host:         ev.Host,
+			Status:       ev.Status,
+			Action:       ev.Action,
+			Msg:          ev.Msg,
+			Duration:     ev.Duration,
+			IgnoreErrors: ev.IgnoreErrors,
+			CreatedAt:    at,
+		})
+		if err != nil {
+			log.Printf("[runs] record result for run %s: %v", r.runID, err)
+		}
+	}
+}
+
+// eventTime converts the plugin's Unix-seconds timestamp, falling back to
+// now if it is missing.
+func eventTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Now()
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// Events returns a run's plays, each with its tasks and per-host results.
+// Runs from before event capture, or that failed before Ansible started,
+// return an empty list.
+func (h *RunsHandler) Events(c *gin.Context) {
+	id := c.Param("id")
+	if r, err := h.runs.Get(id); err != nil || r == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run not found"})
+		return
+	}
+	plays, err := h.runEvents.ListPlays(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plays)
+}
+
+// Hosts returns per-host result totals for a run, with each host's failed
+// and unreachable tasks.
+func (h *RunsHandler) Hosts(c *gin.Context) {
+	id := c.Param("id")
+	if r, err := h.runs.Get(id); err != nil || r == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run not found"})
+		return
+	}
+	hosts, err := h.runEvents.HostSummaries(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hosts)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/brettjrea/ansible-frontend/internal/auth"
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/brettjrea/ansible-frontend/internal/runner"
+	"github.com/gin-gonic/gin"
+)
+
+// runOptionsOverride carries the run options a caller asked to change. Nil
+// fields keep the form's default.
+type runOptionsOverride struct {
+	Check       *bool   `json:"check"`
+	Diff        *bool   `json:"diff"`
+	Limit       *string `json:"limit"`
+	Tags        *string `json:"tags"`
+	SkipTags    *string `json:"skip_tags"`
+	StartAtTask *string `json:"start_at_task"`
+	Forks       *int    `json:"forks"`
+	Verbosity   *int    `json:"verbosity"`
+}
+
+// overrideFromQuery reads run options from webhook query parameters, which
+// use the same names as the JSON fields.
+func overrideFromQuery(c *gin.Context) (runOptionsOverride, error) {
+	var o runOptionsOverride
+	for _, name := range []string{"check", "diff"} {
+		v, ok := c.GetQuery(name)
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return o, fmt.Errorf("%s: must be true or false", name)
+		}
+		if name == "check" {
+			o.Check = &b
+		} else {
+			o.Diff = &b
+		}
+	}
+	for name, dst := range map[string]**string{"limit": &o.Limit, "tags": &o.Tags, "skip_tags": &o.SkipTags, "start_at_task": &o.StartAtTask} {
+		if v, ok := c.GetQuery(name); ok {
+			*dst = &v
+		}
+	}
+	for name, dst := range map[string]**int{"forks": &o.Forks, "verbosity": &o.Verbosity} {
+		v, ok := c.GetQuery(name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return o, fmt.Errorf("%s: must be a number", name)
+		}
+		*dst = &n
+	}
+	return o, nil
+}
+
+// errOptionNotOverridable is returned by resolveRunOptions when a caller
+// changes an option the form does not let them change.
+type errOptionNotOverridable string
+
+func (e errOptionNotOverridable) Error() string {
+	return fmt.Sprintf("option %q cannot be changed on this form", string(e))
+}
+
+// runOptionsErrorStatus maps a resolveRunOptions error to an HTTP status:
+// 403 for an option the caller may not change, 400 for an invalid value.
+func runOptionsErrorStatus(err error) int {
+	if _, ok := err.(errOptionNotOverridable); ok {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// resolveRunOptions applies o on top of the form's default run options and
+// validates the result. When restricted is set only check, diff and the
+// form's overridable options may be changed.
+func resolveRunOptions(form *models.Form, o runOptionsOverride, restricted bool) (models.RunOptions, error) {
+	opts := form.RunOptions
+	allowed := map[string]bool{"check": true, "diff": true}
+	for _, name := range form.OverridableOptions {
+		allowed[name] = true
+	}
+	set := func(name string, changed bool, apply func()) error {
+		if !changed {
+			return nil
+		}
+		if restricted && !allowed[name] {
+			return errOptionNotOverridable(name)
+		}
+		apply()
+		return nil
+	}
+	for _, err := range []error{
+		set("check", o.Check != nil, func() { opts.Check = *o.Check }),
+		set("diff", o.Diff != nil, func() { opts.Diff = *o.Diff }),
+		set("limit", o.Limit != nil && *o.Limit != opts.Limit, func() { opts.Limit = *o.Limit }),
+		set("tags", o.Tags != nil && *o.Tags != opts.Tags, func() { opts.Tags = *o.Tags }),
+		set("skip_tags", o.SkipTags != nil && *o.SkipTags != opts.SkipTags, func() { opts.SkipTags = *o.SkipTags }),
+		set("start_at_task", o.StartAtTask != nil && *o.StartAtTask != opts.StartAtTask, func() { opts.StartAtTask = *o.StartAtTask }),
+		set("forks", o.Forks != nil && *o.Forks != opts.Forks, func() { opts.Forks = *o.Forks }),
+		set("verbosity", o.Verbosity != nil && *o.Verbosity != opts.Verbosity, func() { opts.Verbosity = *o.Verbosity }),
+	} {
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, runner.ValidateOptions(opts)
+}
+
+// canOverrideAllOptions reports whether the caller may change any run
+// option regardless of the form's overridable list (editors and admins).
+func canOverrideAllOptions(c *gin.Context) bool {
+	cl := auth.GetClaims(c)
+	return cl != nil && (cl.Role == "admin" || cl.Role == "editor")
+}
+
+// validateFormRunOptions checks a form's default run options and its list
+// of viewer-overridable option names.
+func validateFormRunOptions(opts models.RunOptions, overridable []string) error {
+	if err := runner.ValidateOptions(opts); err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, name := range models.RunOptionNames {
+		known[name] = true
+	}
+	for _, name := range overridable {
+		if !known[name] {
+			return fmt.Errorf("overridable_options: unknown option %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// queuePollInterval bounds how long an idle worker waits before checking
+	// the queue again when no wake-up arrives.
+	queuePollInterval = 5 * time.Second
+	// maxRunAttempts caps how many times the requeue recovery policy restarts
+	// the same run, so a run that takes the server down can't loop forever.
+	maxRunAttempts = 3
+)
+
+const errNegativeConcurrency = "max_concurrent_runs must be 0 (no limit) or more"
+
+// Recovery policies for runs interrupted by a restart (RUN_RECOVERY).
+const (
+	RecoverFail    = "fail"
+	RecoverRequeue = "requeue"
+)
+
+// RecoverQueue deals with runs the previous process left unfinished and
+// must be called before StartWorkers. policy is RecoverFail (the default
+// when empty) or RecoverRequeue.
+func (h *RunsHandler) RecoverQueue(policy string) error {
+	switch policy {
+	case "", RecoverFail, RecoverRequeue:
+	default:
+		return fmt.Errorf("run recovery policy %q: must be %q or %q", policy, RecoverFail, RecoverRequeue)
+	}
+	requeued, failed, err := h.queue.Recover(policy == RecoverRequeue, maxRunAttempts,
+		"Run interrupted: the server restarted before it finished.")
+	if err != nil {
+		return err
+	}
+	if requeued > 0 || failed > 0 {
+		log.Printf("[queue] recovered interrupted runs: %d requeued, %d failed", requeued, failed)
+	}
+	return nil
+}
+
+// StartWorkers starts n workers that claim and execute queued runs.
+func (h *RunsHandler) StartWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	h.workers = n
+	for i := 0; i < n; i++ {
+		go h.worker()
+	}
+	log.Printf("[queue] started %d run workers", n)
+}
+
+// wakeWorkers tells an idle worker that the queue has changed.
+func (h *RunsHandler) wakeWorkers() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *RunsHandler) worker() {
+	for {
+		runID, inventory, err := h.queue.Claim()
+		if err != nil {
+			log.Printf("[queue] claim: %v", err)
+		}
+		if runID == "" {
+			select {
+			case <-h.wake:
+			case <-time.After(queuePollInterval):
+			}
+			continue
+		}
+		// Pass the wake-up on in case more runs are waiting.
+		h.wakeWorkers()
+		h.runQueued(runID, inventory)
+	}
+}
+
+// runQueued executes a claimed run and removes it from the queue once it
+// has finished.
+func (h *RunsHandler) runQueued(runID, inventory string) {
+	var batchID *string
+	defer func() {
+		if err := h.queue.Done(runID); err != nil {
+			log.Printf("[queue] remove run %s: %v", runID, err)
+		}
+		// A concurrency limit slot may have opened up.
+		h.wakeWorkers()
+		if batchID != nil {
+			h.advanceBatch(*batchID)
+		}
+	}()
+
+	run, err := h.runs.Get(runID)
+	if err != nil || run == nil {
+		log.Printf("[queue] load run %s: %v", runID, err)
+		return
+	}
+	batchID = run.BatchID
+	if run.Status != "pending" {
+		return
+	}
+	if batchID != nil {
+		// Claimed just before its batch was cancelled.
+		if b, _ := h.batches.Get(*batchID); b != nil && b.Status == "cancelling" {
+			h.runs.Finish(runID, "cancelled", b.Message)
+			return
+		}
+	}
+	if run.FormID == nil {
+		h.runs.Finish(runID, "failed", "form was deleted before the run started")
+		return
+	}
+	form, err := h.forms.Get(*run.FormID)
+	if err != nil || form == nil {
+		h.runs.Finish(runID, "failed", fmt.Sprintf("form not found: %v", err))
+		return
+	}
+	var variables map[string]interface{}
+	json.Unmarshal([]byte(run.Variables), &variables)
+
+	if inventory != "" {
+		h.executeRunWithInventory(runID, form, inventory, h.inventoryHostCerts(inventory), variables, run.Options)
+	} else {
+		h.executeRun(runID, form, variables, run.Options)
+	}
+}
+
+// Queue lists queued runs in order, and the runs workers are executing.
+func (h *RunsHandler) Queue(c *gin.Context) {
+	entries, err := h.queue.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	queued, active := entries[:0:0], entries[:0:0]
+	for _, e := range entries {
+		if e.ClaimedAt == nil {
+			queued = append(queued, e)
+		} else {
+			active = append(active, e)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"workers": h.workers, "active": active, "queued": queued})
+}
@@ -3,14 +3,16 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/auth"
 	"github.com/brettjrea/ansible-frontend/internal/models"
@@ -18,7 +20,7 @@ import (
 	"github.com/brettjrea/ansible-frontend/internal/runner"
 	"github.com/brettjrea/ansible-frontend/internal/store"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
 )
 
 // liveRun holds in-progress output and SSE subscribers for a single run.
@@ -29,45 +31,66 @@ type liveRun struct {
 	done     bool
 	status   string
 	cancelFn context.CancelFunc
+	// cancelledBy is the user who cancelled the run, set before cancelFn is called.
+	cancelledBy string
 }
 
 type RunsHandler struct {
-	runs         *store.RunStore
-	forms        *store.FormStore
-	servers      *store.ServerStore
-	serverGroups *store.ServerGroupStore
-	playbooks    *store.PlaybookStore
-	vaults       *store.VaultStore
-	hosts        *store.HostStore
-	sshCerts     *store.SSHCertStore
-	audit        *store.AuditStore
-	jwtSvc       *auth.JWTService
-	liveRuns     sync.Map // string -> *liveRun
+	runs            *store.RunStore
+	runEvents       *store.RunEventStore
+	forms           *store.FormStore
+	servers         *store.ServerStore
+	serverGroups    *store.ServerGroupStore
+	playbooks       *store.PlaybookStore
+	vaults          *store.VaultStore
+	hosts           *store.HostStore
+	inventoryGroups *store.InventoryGroupStore
+	sshCerts        *store.SSHCertStore
+	hostFacts       *store.HostFactsStore
+	audit           *store.AuditStore
+	jwtSvc          *auth.JWTService
+	queue           *store.RunQueueStore
+	batches         *store.BatchStore
+	batchMu         sync.Mutex // serialises rollout decisions (advanceBatch)
+	workers         int
+	wake            chan struct{}
+	liveRuns        sync.Map // string -> *liveRun
 }
 
 func NewRunsHandler(
 	runs *store.RunStore,
+	runEvents *store.RunEventStore,
 	forms *store.FormStore,
 	servers *store.ServerStore,
 	serverGroups *store.ServerGroupStore,
 	playbooks *store.PlaybookStore,
 	vaults *store.VaultStore,
 	hosts *store.HostStore,
+	inventoryGroups *store.InventoryGroupStore,
 	sshCerts *store.SSHCertStore,
+	hostFacts *store.HostFactsStore,
 	audit *store.AuditStore,
 	jwtSvc *auth.JWTService,
+	queue *store.RunQueueStore,
+	batches *store.BatchStore,
 ) *RunsHandler {
 	return &RunsHandler{
-		runs:         runs,
-		forms:        forms,
-		servers:      servers,
-		serverGroups: serverGroups,
-		playbooks:    playbooks,
-		vaults:       vaults,
-		hosts:        hosts,
-		sshCerts:     sshCerts,
-		audit:        audit,
-		jwtSvc:       jwtSvc,
+		runs:            runs,
+		runEvents:       runEvents,
+		forms:           forms,
+		servers:         servers,
+		serverGroups:    serverGroups,
+		playbooks:       playbooks,
+		vaults:          vaults,
+		hosts:           hosts,
+		inventoryGroups: inventoryGroups,
+		sshCerts:        sshCerts,
+		hostFacts:       hostFacts,
+		audit:           audit,
+		jwtSvc:          jwtSvc,
+		queue:           queue,
+		batches:         batches,
+		wake:            make(chan struct{}, 1),
 	}
 }
 
@@ -76,11 +99,16 @@ func NewRunsHandler(
 func (h *RunsHandler) List(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	filter, err := runFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	total, _ := h.runs.Count()
+	total, _ := h.runs.Count(filter)
 	c.Header("X-Total-Count", strconv.Itoa(total))
 
-	list, err := h.runs.List(limit, offset)
+	list, err := h.runs.List(filter, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -88,9 +116,28 @@ func (h *RunsHandler) List(c *gin.Context) {
 	if list == nil {
 		list = []*models.Run{}
 	}
+	h.setQueuePositions(list...)
 	c.JSON(http.StatusOK, list)
 }
 
+// runFilterFromQuery reads the status, changed, failed and unreachable
+// filters of GET /runs.
+func runFilterFromQuery(c *gin.Context) (store.RunFilter, error) {
+	f := store.RunFilter{Status: c.Query("status")}
+	for name, dst := range map[string]**bool{"changed": &f.Changed, "failed": &f.Failed, "unreachable": &f.Unreachable} {
+		v, ok := c.GetQuery(name)
+		if !ok || v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("%s: must be true or false", name)
+		}
+		*dst = &b
+	}
+	return f, nil
+}
+
 func (h *RunsHandler) Get(c *gin.Context) {
 	r, err := h.runs.Get(c.Param("id"))
 	if err != nil || r == nil {
@@ -106,13 +153,35 @@ func (h *RunsHandler) Get(c *gin.Context) {
 		}
 		lr.mu.Unlock()
 	}
+	h.setQueuePositions(r)
 	c.JSON(http.StatusOK, r)
 }
 
+// setQueuePositions fills in QueuePosition on pending runs.
+func (h *RunsHandler) setQueuePositions(runs ...*models.Run) {
+	var positions map[string]int
+	for _, r := range runs {
+		if r.Status != "pending" {
+			continue
+		}
+		if positions == nil {
+			var err error
+			if positions, err = h.queue.Positions(); err != nil {
+				log.Printf("[queue] positions: %v", err)
+				return
+			}
+		}
+		r.QueuePosition = positions[r.ID]
+	}
+}
+
 func (h *RunsHandler) Create(c *gin.Context) {
 	var req struct {
 		FormID    string                 `json:"form_id" binding:"required"`
 		Variables map[string]interface{} `json:"variables"`
+		CheckMode bool                   `json:"check_mode"`
+		DiffMode  bool                   `json:"diff_mode"`
+		Options   runOptionsOverride     `json:"options"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -125,7 +194,21 @@ func (h *RunsHandler) Create(c *gin.Context) {
 		return
 	}
 
-	runID, batchID, runIDs, err := h.launchFormRuns(form, req.Variables)
+	// check_mode/diff_mode predate the options object and still apply when
+	// options leaves check/diff unset.
+	if req.Options.Check == nil && req.CheckMode {
+		req.Options.Check = &req.CheckMode
+	}
+	if req.Options.Diff == nil && req.DiffMode {
+		req.Options.Diff = &req.DiffMode
+	}
+	opts, err := resolveRunOptions(form, req.Options, !canOverrideAllOptions(c))
+	if err != nil {
+		c.JSON(runOptionsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	runID, batchID, runIDs, err := h.launchFormRuns(form, req.Variables, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -133,19 +216,23 @@ func (h *RunsHandler) Create(c *gin.Context) {
 
 	uid, uname := auditUser(c)
 	if batchID != "" {
-		h.audit.Log(uid, uname, "create", "batch-run", batchID, "", c.ClientIP())
+		h.audit.Log(uid, uname, "create", "batch-run", batchID, runModeDetails(opts), c.ClientIP())
 		c.JSON(http.StatusAccepted, gin.H{"batch_id": batchID, "run_ids": runIDs, "status": "pending"})
 	} else {
-		h.audit.Log(uid, uname, "create", "run", runID, "", c.ClientIP())
+		h.audit.Log(uid, uname, "create", "run", runID, runModeDetails(opts), c.ClientIP())
 		c.JSON(http.StatusAccepted, gin.H{"run_id": runID, "status": "pending"})
 	}
 }
 
-// launchFormRuns creates run records and launches goroutines for a form.
-// server_id is always the job runner; host_id or server_group_id is the ansible target.
-// For server-group forms it creates one run per member and returns batchID+runIDs.
-// For single-host (or no explicit target) forms it returns runID.
-func (h *RunsHandler) launchFormRuns(form *models.Form, variables map[string]interface{}) (runID, batchID string, runIDs []string, err error) {
+// launchFormRuns creates run records for a form and queues them for the workers.
+// server_id is always the job runner; host_id, server_group_id, inventory_group_id
+// or host_selector is the ansible target.
+// For server-group forms it creates one run per member and returns batchID+runIDs,
+// unless the form's group_run_mode is single.
+// For other forms (single host, inventory group, host selector, single-mode
+// group or no explicit target) it returns runID.
+// opts holds the resolved ansible-playbook options and is recorded on every run created.
+func (h *RunsHandler) launchFormRuns(form *models.Form, variables map[string]interface{}, opts models.RunOptions) (runID, batchID string, runIDs []string, err error) {
 	varJSON, _ := json.Marshal(variables)
 	fid := form.ID
 
@@ -153,7 +240,15 @@ func (h *RunsHandler) launchFormRuns(form *models.Form, variables map[string]int
 		return "", "", nil, fmt.Errorf("form has no job runner configured")
 	}
 
-	if form.ServerGroupID != nil {
+	if form.ServerGroupID != nil && form.GroupRunMode == "single" {
+		members, merr := h.serverGroups.GetMembers(*form.ServerGroupID)
+		if merr != nil {
+			return "", "", nil, fmt.Errorf("load server group members: %w", merr)
+		}
+		if len(members) == 0 {
+			return "", "", nil, fmt.Errorf("server group has no members")
+		}
+	} else if form.ServerGroupID != nil {
 		members, merr := h.serverGroups.GetMembers(*form.ServerGroupID)
 		if merr != nil {
 			return "", "", nil, fmt.Errorf("load server group members: %w", merr)
@@ -161,26 +256,141 @@ func (h *RunsHandler) launchFormRuns(form *models.Form, variables map[string]int
 		if len(members) == 0 {
 			return "", "", nil, fmt.Errorf("server group has no members")
 		}
-		bid := uuid.New().String()
-		for _, server := range members {
-			run, rerr := h.runs.Create(&fid, form.PlaybookID, *form.ServerID, string(varJSON), &bid)
+		// Members run in rollout batches of size; only the first is queued
+		// now and advanceBatch starts the rest.
+		size := rolloutBatchSize(form.Rollout, len(members))
+		batch, berr := h.batches.Create(&fid, form.Rollout, (len(members)+size-1)/size)
+		if berr != nil {
+			return "", "", nil, fmt.Errorf("create batch: %w", berr)
+		}
+		// Every member's run is created before any is queued, so a failure
+		// leaves no partial rollout running: the runs created so far are
+		// cancelled and the batch fails.
+		runs := make([]*models.Run, 0, len(members))
+		for i, host := range members {
+			phase := i/size + 1
+			inventory := buildInventory(host.Name, host.Address, host.Vars)
+			run, rerr := h.runs.Create(&fid, form.PlaybookID, *form.ServerID, string(varJSON), &batch.ID, phase, nil, inventory, opts)
 			if rerr != nil {
-				continue
+				msg := fmt.Sprintf("create run for %s: %v", host.Name, rerr)
+				for _, r := range runs {
+					h.runs.Finish(r.ID, "cancelled", "rollout not started: "+msg)
+				}
+				if ferr := h.batches.Finish(batch.ID, "failed", msg); ferr != nil {
+					log.Printf("[batches] finish batch %s: %v", batch.ID, ferr)
+				}
+				return "", "", nil, errors.New(msg)
+			}
+			runs = append(runs, run)
+		}
+		for _, run := range runs {
+			if run.BatchPhase == 1 {
+				if qerr := h.queue.Enqueue(run.ID, run.Inventory); qerr != nil {
+					log.Printf("[batches] queue run %s of batch %s: %v", run.ID, batch.ID, qerr)
+					h.runs.Finish(run.ID, "failed", fmt.Sprintf("queue run: %v", qerr))
+				}
 			}
 			runIDs = append(runIDs, run.ID)
-			go h.executeRunWithInventory(run.ID, form, "[all]\n"+server.Host+"\n", nil, variables)
 		}
-		return "", bid, runIDs, nil
+		h.wakeWorkers()
+		// Moves on straight away if nothing in the first batch could be queued.
+		h.advanceBatch(batch.ID)
+		return "", batch.ID, runIDs, nil
 	}
 
-	run, rerr := h.runs.Create(&fid, form.PlaybookID, *form.ServerID, string(varJSON), nil)
+	run, rerr := h.runs.Create(&fid, form.PlaybookID, *form.ServerID, string(varJSON), nil, 0, nil, "", opts)
 	if rerr != nil {
 		return "", "", nil, rerr
 	}
-	go h.executeRun(run.ID, form, variables)
+	if qerr := h.queue.Enqueue(run.ID, ""); qerr != nil {
+		h.runs.Finish(run.ID, "failed", fmt.Sprintf("queue run: %v", qerr))
+		return "", "", nil, qerr
+	}
+	h.wakeWorkers()
 	return run.ID, "", nil, nil
 }
 
+// Relaunch starts a finished run again with its stored variables, form,
+// target and options, linked to it as its parent. With failed_hosts_only the
+// new run is limited (--limit) to the hosts the previous run's PLAY RECAP
+// counted as failed or unreachable.
+func (h *RunsHandler) Relaunch(c *gin.Context) {
+	var req struct {
+		FailedHostsOnly bool `json:"failed_hosts_only"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parent, err := h.runs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if parent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run not found"})
+		return
+	}
+	if parent.Status == "pending" || parent.Status == "running" {
+		c.JSON(http.StatusConflict, gin.H{"error": "run has not finished"})
+		return
+	}
+	if parent.FormID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "the run's form has been deleted"})
+		return
+	}
+	form, err := h.forms.Get(*parent.FormID)
+	if err != nil || form == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "the run's form has been deleted"})
+		return
+	}
+	if form.ServerID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "form has no job runner configured"})
+		return
+	}
+	if parent.BatchID != nil && parent.Inventory == "" {
+		// Member runs from before targets were stored on the run.
+		c.JSON(http.StatusConflict, gin.H{"error": "the run's target host was not recorded; run the form again"})
+		return
+	}
+
+	opts := parent.Options
+	details := "relaunch of " + parent.ID
+	if req.FailedHostsOnly {
+		hosts, err := h.runs.FailedHosts(parent.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(hosts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "run has no failed or unreachable hosts"})
+			return
+		}
+		opts.Limit = strings.Join(hosts, ",")
+		details += ", failed hosts only"
+	}
+
+	run, err := h.runs.Create(&form.ID, form.PlaybookID, *form.ServerID, parent.Variables, nil, 0, &parent.ID, parent.Inventory, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.queue.Enqueue(run.ID, parent.Inventory); err != nil {
+		h.runs.Finish(run.ID, "failed", fmt.Sprintf("queue run: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.wakeWorkers()
+
+	uid, uname := auditUser(c)
+	if md := runModeDetails(opts); md != "" {
+		details += "; " + md
+	}
+	h.audit.Log(uid, uname, "relaunch", "run", run.ID, details, c.ClientIP())
+	c.JSON(http.StatusAccepted, gin.H{"run_id": run.ID, "parent_run_id": parent.ID, "status": "pending"})
+}
+
 // ── SSE streaming ─────────────────────────────────────────────────────────────
 
 // Stream serves a run's output as a Server-Sent Events stream.
@@ -219,6 +429,30 @@ func (h *RunsHandler) Stream(c *gin.Context) {
 		w.Flush()
 	}
 
+	// A queued run has no live output yet: report its queue position until a
+	// worker starts it.
+	ctx := c.Request.Context()
+	lastPos := -1
+	for run.Status == "pending" || run.Status == "running" {
+		if _, ok := h.liveRuns.Load(id); ok {
+			break
+		}
+		h.setQueuePositions(run)
+		if run.QueuePosition != lastPos {
+			lastPos = run.QueuePosition
+			fmt.Fprintf(w, "event: queued\ndata: %d\n\n", lastPos)
+			w.Flush()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		if run, _ = h.runs.Get(id); run == nil {
+			return
+		}
+	}
+
 	if val, ok := h.liveRuns.Load(id); ok {
 		lr := val.(*liveRun)
 		lr.mu.Lock()
@@ -244,7 +478,6 @@ func (h *RunsHandler) Stream(c *gin.Context) {
 			sendLine(line)
 		}
 
-		ctx := c.Request.Context()
 		for {
 			select {
 			case line, ok := <-ch:
@@ -326,24 +559,98 @@ func (h *RunsHandler) finishLiveRun(runID, status string) {
 
 // ── Execution ─────────────────────────────────────────────────────────────────
 
-// Cancel stops an in-progress run by cancelling its context.
+// Cancel stops an in-progress run by cancelling its context, or removes a
+// run that is still waiting in the queue.
 func (h *RunsHandler) Cancel(c *gin.Context) {
-	val, ok := h.liveRuns.Load(c.Param("id"))
-	if !ok {
+	id := c.Param("id")
+	_, uname := auditUser(c)
+	stopped, err := h.stopRun(id, uname)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !stopped {
+		if r, _ := h.runs.Get(id); r != nil && r.Status == "pending" {
+			// Claimed by a worker but not started yet.
+			c.JSON(http.StatusConflict, gin.H{"error": "run is starting; try again"})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "run not in progress"})
 		return
 	}
+	h.auditCancel(c, id)
+	if r, _ := h.runs.Get(id); r != nil && r.BatchID != nil {
+		go h.advanceBatch(*r.BatchID)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// stopRun cancels run id on behalf of user by: a queued run, or one held for
+// a later rollout batch, is finished as cancelled, and an executing run is
+// stopped. It reports false when the run was neither.
+func (h *RunsHandler) stopRun(id, by string) (bool, error) {
+	val, ok := h.liveRuns.Load(id)
+	if !ok {
+		note := fmt.Sprintf("Cancelled by %s before it started.", by)
+		removed, err := h.queue.Unqueue(id)
+		if err != nil {
+			return false, err
+		}
+		if removed {
+			err = h.runs.Finish(id, "cancelled", note)
+		} else {
+			// Not queued: it may be waiting for a later rollout batch.
+			removed, err = h.runs.CancelHeld(id, note)
+		}
+		if err != nil || !removed {
+			return false, err
+		}
+		h.runs.SetCancelledBy(id, by)
+		return true, nil
+	}
 	lr := val.(*liveRun)
 	lr.mu.Lock()
+	if lr.done {
+		lr.mu.Unlock()
+		return false, nil
+	}
+	if lr.cancelledBy == "" {
+		lr.cancelledBy = by
+	}
 	if lr.cancelFn != nil {
 		lr.cancelFn()
 	}
 	lr.mu.Unlock()
-	c.Status(http.StatusNoContent)
+	h.runs.SetCancelledBy(id, by)
+	return true, nil
+}
+
+func (h *RunsHandler) auditCancel(c *gin.Context, runID string) {
+	uid, uname := auditUser(c)
+	h.audit.Log(uid, uname, "cancel", "run", runID, "", c.ClientIP())
 }
 
-// executeRun loads the form's job runner and optional host target then delegates to executeRunWithInventory.
-func (h *RunsHandler) executeRun(runID string, form *models.Form, variables map[string]interface{}) {
+// stopStatus reports why a run's context ended early: "cancelled" when a
+// user cancelled it, "timed_out" when the form's maximum runtime passed.
+// It returns "" while the context is live, along with the line to append
+// to the run's output.
+func (lr *liveRun) stopStatus(ctx context.Context, form *models.Form) (status, note string) {
+	lr.mu.Lock()
+	by := lr.cancelledBy
+	lr.mu.Unlock()
+	switch {
+	case by != "":
+		return "cancelled", fmt.Sprintf("Cancelled by %s.", by)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timed_out", fmt.Sprintf("Timed out after %d minute(s) (the form's maximum runtime).", form.MaxRuntimeMinutes)
+	}
+	return "", ""
+}
+
+// executeRun loads the form's job runner and optional host, inventory group,
+// host selector or single-mode server group target then delegates to
+// executeRunWithInventory.
+func (h *RunsHandler) executeRun(runID string, form *models.Form, variables map[string]interface{}, opts models.RunOptions) {
 	if form.ServerID == nil {
 		h.runs.Finish(runID, "failed", "form has no job runner configured")
 		return
@@ -354,21 +661,43 @@ func (h *RunsHandler) executeRun(runID string, form *models.Form, variables map[
 		return
 	}
 	var inventoryTarget string
-	var sshCertContent []byte
+	var hostCerts map[string][]byte
 	if form.HostID != nil {
 		host, herr := h.hosts.Get(*form.HostID)
 		if herr == nil && host != nil {
 			inventoryTarget = buildInventory(host.Name, host.Address, host.Vars)
-			if host.SSHCertID != nil {
-				sshCertContent, _ = h.sshCerts.GetDecryptedCert(*host.SSHCertID)
-			}
+			hostCerts = h.hostCerts([]*models.Host{host})
+		}
+	} else if form.InventoryGroupID != nil {
+		inventoryTarget, hostCerts, err = h.inventoryGroupTarget(*form.InventoryGroupID)
+		if err != nil {
+			h.runs.Finish(runID, "failed", err.Error())
+			return
+		}
+	} else if form.HostSelector != "" {
+		inventoryTarget, hostCerts, err = h.selectorTarget(form.HostSelector)
+		if err != nil {
+			h.runs.Finish(runID, "failed", err.Error())
+			return
+		}
+	} else if form.ServerGroupID != nil {
+		// Only single-mode group runs get here; member runs carry their inventory.
+		members, merr := h.serverGroups.GetMembers(*form.ServerGroupID)
+		if merr != nil {
+			h.runs.Finish(runID, "failed", fmt.Sprintf("load server group members: %v", merr))
+			return
+		}
+		if len(members) == 0 {
+			h.runs.Finish(runID, "failed", "server group has no members")
+			return
 		}
+		inventoryTarget, hostCerts = buildGroupInventory(members), h.hostCerts(members)
 	}
-	h.executeRunWithInventory(runID, form, inventoryTarget, sshCertContent, variables)
+	h.executeRunWithInventory(runID, form, inventoryTarget, hostCerts, variables, opts)
 }
 
 // executeRunWithInventory loads the job runner server then delegates to executeRunWithServer.
-func (h *RunsHandler) executeRunWithInventory(runID string, form *models.Form, inventoryTarget string, sshCertContent []byte, variables map[string]interface{}) {
+func (h *RunsHandler) executeRunWithInventory(runID string, form *models.Form, inventoryTarget string, hostCerts map[string][]byte, variables map[string]interface{}, opts models.RunOptions) {
 	if form.ServerID == nil {
 		h.runs.Finish(runID, "failed", "form has no job runner configured")
 		return
@@ -378,37 +707,59 @@ func (h *RunsHandler) executeRunWithInventory(runID string, form *models.Form, i
 		h.runs.Finish(runID, "failed", fmt.Sprintf("runner not found: %v", err))
 		return
 	}
-	h.executeRunWithServer(runID, form, server, inventoryTarget, sshCertContent, variables)
+	h.executeRunWithServer(runID, form, server, inventoryTarget, hostCerts, variables, opts)
 }
 
-// executeRunWithServer performs ansible execution for a specific server.
-// It dispatches to the K8s Job runner when the server has an ExecutionEnvironment
-// image configured, and falls back to the SSH runner otherwise.
-func (h *RunsHandler) executeRunWithServer(runID string, form *models.Form, server *models.Server, inventoryTarget string, sshCertContent []byte, variables map[string]interface{}) {
+// executeRunWithServer performs ansible execution for a specific server,
+// using the runner backend selected by the server's runner_type.
+func (h *RunsHandler) executeRunWithServer(runID string, form *models.Form, server *models.Server, inventoryTarget string, hostCerts map[string][]byte, variables map[string]interface{}, opts models.RunOptions) {
 	ctx, cancel := context.WithCancel(context.Background())
+	if form.MaxRuntimeMinutes > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(form.MaxRuntimeMinutes)*time.Minute)
+	}
+	defer cancel()
 
 	lr := &liveRun{cancelFn: cancel}
 	h.liveRuns.Store(runID, lr)
 
 	fail := func(msg string) {
-		h.runs.Finish(runID, "failed", msg)
-		h.finishLiveRun(runID, "failed")
+		status := "failed"
+		if stopped, note := lr.stopStatus(ctx, form); stopped != "" {
+			status, msg = stopped, msg+"\n"+note
+		}
+		h.runs.Finish(runID, status, msg)
+		h.finishLiveRun(runID, status)
 	}
 
 	h.runs.SetRunning(runID)
 
+	rn, err := runnerForServer(h.servers, server)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
 	playbook, err := h.playbooks.Get(form.PlaybookID)
 	if err != nil || playbook == nil {
 		fail(fmt.Sprintf("playbook not found: %v", err))
 		return
 	}
 
-	playbookContent, err := fetchPlaybookContent(ctx, playbook, form.PlaybookPath)
+	src, err := fetchPlaybookSource(ctx, playbook, form.PlaybookPath)
 	if err != nil {
 		fail(fmt.Sprintf("fetch playbook: %v", err))
 		return
 	}
 
+	galaxy := runner.GalaxyServer{URL: playbook.GalaxyServerURL}
+	if playbook.HasGalaxyToken {
+		galaxy.Token, err = h.playbooks.GetDecryptedGalaxyToken(playbook.ID)
+		if err != nil {
+			fail(fmt.Sprintf("decrypt galaxy token: %v", err))
+			return
+		}
+	}
+
 	var vaultPassword string
 	var vaultFileContent []byte
 	var vaultFileName string
@@ -440,72 +791,78 @@ func (h *RunsHandler) executeRunWithServer(runID string, form *models.Form, serv
 	doneCh := make(chan struct{})
 	go func() {
 		defer close(doneCh)
+		events := newRunEventRecorder(h.runEvents, runID)
 		for line := range outputCh {
+			// Callback plugin events are recorded, not shown in the output.
+			if ev, rest, ok := runner.ParseEvent(line); ok {
+				events.record(ev)
+				if ev.Facts != nil {
+					if err := cacheHostFacts(h.hosts, h.hostFacts, ev.Host, ev.Facts, "run", &runID); err != nil {
+						log.Printf("[runs] cache facts of %s for run %s: %v", ev.Host, runID, err)
+					}
+				}
+				if rest == "" {
+					continue
+				}
+				line = rest
+			}
 			outputBuilder.WriteString(line + "\n")
 			h.broadcastLine(runID, line)
 		}
 	}()
 
-	var result runner.RunResult
-
-	if server.ExecutionEnvironment != "" {
-		// ── Kubernetes Execution Environment ─────────────────────────────────
-		k8s, kerr := runner.GetK8sRunner()
-		if kerr != nil {
-			fail(fmt.Sprintf("k8s runner unavailable: %v", kerr))
-			close(outputCh)
-			<-doneCh
-			return
-		}
-		result = k8s.RunPlaybook(ctx, runID, server.ExecutionEnvironment, playbookContent,
-			inventoryTarget, variables, server.PreCommand, vaultPassword, vaultFileContent, vaultFileName, sshCertContent, outputCh)
-	} else {
-		// ── SSH runner ────────────────────────────────────────────────────────
-		sshClient, cerr := runner.Connect(server.Host, server.Port, server.Username, server.SSHPrivateKey)
-		if cerr != nil {
-			fail(fmt.Sprintf("SSH connect failed: %v", cerr))
-			close(outputCh)
-			<-doneCh
-			return
-		}
-		defer sshClient.Close()
-
-		remotePath := fmt.Sprintf("/tmp/ansible-run-%s.yml", runID)
-		if uerr := sshClient.UploadFile(playbookContent, remotePath); uerr != nil {
-			fail(fmt.Sprintf("upload playbook: %v", uerr))
-			close(outputCh)
-			<-doneCh
-			return
-		}
-		defer sshClient.RunCommand(fmt.Sprintf("rm -f '%s'", remotePath))
-
-		result = sshClient.RunPlaybook(ctx, remotePath, variables, inventoryTarget, server.PreCommand, vaultPassword, vaultFileContent, vaultFileName, sshCertContent, outputCh)
+	spec := &runner.RunSpec{
+		RunID:         runID,
+		Source:        src,
+		Inventory:     inventoryTarget,
+		HostCerts:     hostCerts,
+		Variables:     variables,
+		VaultPassword: vaultPassword,
+		VaultFile:     vaultFileContent,
+		VaultFileName: vaultFileName,
+		Galaxy:        galaxy,
+		PreCommand:    server.PreCommand,
+		Options:       opts,
 	}
+	result := rn.Run(ctx, spec, outputCh)
 
 	close(outputCh)
 	<-doneCh
 
 	fullOutput := outputBuilder.String()
-	if result.Err != nil {
-		fullOutput += "\nRunner error: " + result.Err.Error()
-	}
-
 	status := "success"
-	if result.ExitCode != 0 || result.Err != nil {
+	if stopped, note := lr.stopStatus(ctx, form); stopped != "" {
+		status = stopped
+		fullOutput += "\n" + note
+		h.broadcastLine(runID, note)
+	} else if result.Err != nil || result.ExitCode != 0 {
 		status = "failed"
+		if result.Err != nil {
+			fullOutput += "\nRunner error: " + result.Err.Error()
+		}
 	}
 
 	h.runs.Finish(runID, status, fullOutput)
+	if recap := runner.ParseRecap(fullOutput); recap != nil {
+		if err := h.runs.SetRecap(runID, recap); err != nil {
+			log.Printf("[runs] save recap for run %s: %v", runID, err)
+		}
+	}
 	h.finishLiveRun(runID, status)
 
 	// Fire completion notifications (webhook + email) if configured on the form.
+	// Batch member runs are reported once for the whole batch by finishBatch.
 	if form.NotifyWebhook != "" || form.NotifyEmail != "" {
-		go notify.Send(form.NotifyWebhook, form.NotifyEmail, runID, status, form.Name)
+		if run, _ := h.runs.Get(runID); run != nil && run.BatchID == nil {
+			go notify.Send(form.NotifyWebhook, form.NotifyEmail, runID, status, form.Name)
+		}
 	}
 }
 
 // TriggerWebhook handles unauthenticated webhook triggers via a form's token.
-// POST /api/webhook/forms/:token
+// POST /api/webhook/forms/:token[?check=true][&diff=true][&limit=...]
+// The JSON body holds variable overrides, so run options are query params.
+// Webhook callers may change the same options as a viewer.
 func (h *RunsHandler) TriggerWebhook(c *gin.Context) {
 	token := c.Param("token")
 	form, err := h.forms.GetByWebhookToken(token)
@@ -531,23 +888,35 @@ func (h *RunsHandler) TriggerWebhook(c *gin.Context) {
 		}
 	}
 
-	runID, batchID, runIDs, err := h.launchFormRuns(form, variables)
+	override, err := overrideFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := resolveRunOptions(form, override, true)
+	if err != nil {
+		c.JSON(runOptionsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	runID, batchID, runIDs, err := h.launchFormRuns(form, variables, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	if batchID != "" {
-		h.audit.Log("", "webhook", "trigger", "batch-run", batchID, "", c.ClientIP())
+		h.audit.Log("", "webhook", "trigger", "batch-run", batchID, runModeDetails(opts), c.ClientIP())
 		c.JSON(http.StatusAccepted, gin.H{"batch_id": batchID, "run_ids": runIDs, "status": "pending"})
 	} else {
-		h.audit.Log("", "webhook", "trigger", "run", runID, "", c.ClientIP())
+		h.audit.Log("", "webhook", "trigger", "run", runID, runModeDetails(opts), c.ClientIP())
 		c.JSON(http.StatusAccepted, gin.H{"run_id": runID, "status": "pending"})
 	}
 }
 
-// fetchPlaybookContent clones the playbook source repo and returns the content
-// of playbookPath within it. The clone directory is removed after the read.
-func fetchPlaybookContent(ctx context.Context, p *models.Playbook, playbookPath string) ([]byte, error) {
+// fetchPlaybookSource clones the playbook source repo and packs the whole
+// checkout so roles/, group_vars/, templates/, files/ and ansible.cfg travel
+// with the playbook. The clone directory is removed after packing.
+func fetchPlaybookSource(ctx context.Context, p *models.Playbook, playbookPath string) (*runner.Source, error) {
 	dir, err := os.MkdirTemp("", "ansible-clone-*")
 	if err != nil {
 		return nil, fmt.Errorf("tempdir: %w", err)
@@ -557,38 +926,221 @@ func fetchPlaybookContent(ctx context.Context, p *models.Playbook, playbookPath
 	if err := cloneShallow(ctx, p, dir); err != nil {
 		return nil, err
 	}
+	return runner.PackSource(dir, playbookPath)
+}
 
-	content, err := os.ReadFile(filepath.Join(dir, playbookPath))
-	if err != nil {
-		return nil, fmt.Errorf("read %s: %w", playbookPath, err)
+// runModeDetails describes a run's non-default options for the audit log.
+func runModeDetails(opts models.RunOptions) string {
+	var parts []string
+	if opts.Check {
+		parts = append(parts, "check mode")
+	}
+	if opts.Diff {
+		parts = append(parts, "diff mode")
+	}
+	if opts.Limit != "" {
+		parts = append(parts, "limit="+opts.Limit)
+	}
+	if opts.Tags != "" {
+		parts = append(parts, "tags="+opts.Tags)
+	}
+	if opts.SkipTags != "" {
+		parts = append(parts, "skip-tags="+opts.SkipTags)
+	}
+	if opts.StartAtTask != "" {
+		parts = append(parts, "start-at-task="+opts.StartAtTask)
+	}
+	if opts.Forks > 0 {
+		parts = append(parts, "forks="+strconv.Itoa(opts.Forks))
+	}
+	if opts.Verbosity > 0 {
+		parts = append(parts, "-"+strings.Repeat("v", opts.Verbosity))
 	}
-	return content, nil
+	return strings.Join(parts, ", ")
+}
+
+// runnerForServer returns the Runner backend configured on a job runner.
+// SSH runners connect through the server's jump host chain.
+func runnerForServer(servers *store.ServerStore, server *models.Server) (runner.Runner, error) {
+	switch server.RunnerType {
+	case runner.KindKubernetes:
+		return runner.NewExecutionEnvironmentRunner(server.ExecutionEnvironment), nil
+	case runner.KindLocal:
+		return runner.NewLocalRunner(), nil
+	default:
+		chain, err := serverChain(servers, server)
+		if err != nil {
+			return nil, err
+		}
+		return runner.NewSSHRunner(serverHops(chain)), nil
+	}
+}
+
+// buildInventory creates a YAML inventory with one host under all.hosts,
+// using its name as the alias, with ansible_host set to address when it
+// differs from name and its host vars alongside, keeping their types.
+func buildInventory(name, address string, vars map[string]interface{}) string {
+	return buildGroupInventory([]*models.Host{{Name: name, Address: address, Vars: vars}})
 }
 
-// buildInventory creates a simple INI inventory with one host entry.
-// The host is placed in [all] using its name as the alias, with ansible_host
-// set to address when it differs from name, and any host vars appended inline.
-func buildInventory(name, address string, vars map[string]string) string {
-	entry := name
+// inventoryHostVars is what a YAML inventory holds for a host: ansible_host
+// when address differs from name, and its host vars, which may override it.
+func inventoryHostVars(name, address string, vars map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(vars)+1)
 	if address != "" && address != name {
-		entry += " ansible_host=" + address
+		out["ansible_host"] = address
 	}
 	for k, v := range vars {
-		entry += " " + k + `="` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		out[k] = v
+	}
+	return out
+}
+
+// renderYAMLInventory writes all as the implicit all group of a YAML
+// inventory. Host vars come from JSON, so encoding them cannot fail.
+func renderYAMLInventory(all *yamlInventoryGroup) string {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	_ = enc.Encode(map[string]*yamlInventoryGroup{"all": all})
+	_ = enc.Close()
+	return b.String()
+}
+
+// inventoryGroupTarget builds the inventory of an inventory group and its
+// descendants, with the SSH certs of the hosts in it.
+func (h *RunsHandler) inventoryGroupTarget(groupID string) (string, map[string][]byte, error) {
+	groups, err := h.inventoryGroups.List()
+	if err != nil {
+		return "", nil, fmt.Errorf("load inventory groups: %w", err)
+	}
+	var root *models.InventoryGroup
+	for _, g := range groups {
+		if g.ID == groupID {
+			root = g
+		}
+	}
+	if root == nil {
+		return "", nil, fmt.Errorf("inventory group not found")
+	}
+	hosts, err := h.hosts.List()
+	if err != nil {
+		return "", nil, fmt.Errorf("load hosts: %w", err)
+	}
+	inventory, included := buildGroupTreeInventory(root, groups, hosts)
+	if len(included) == 0 {
+		return "", nil, fmt.Errorf("inventory group %s has no hosts", root.Name)
+	}
+	return inventory, h.hostCerts(included), nil
+}
+
+// selectorTarget builds the inventory of the hosts whose labels match a
+// form's host selector at the time the run starts, with their SSH certs.
+func (h *RunsHandler) selectorTarget(expr string) (string, map[string][]byte, error) {
+	sel, err := parseHostSelector(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	hosts, err := h.hosts.List()
+	if err != nil {
+		return "", nil, fmt.Errorf("load hosts: %w", err)
+	}
+	matched := selectHosts(hosts, sel)
+	if len(matched) == 0 {
+		return "", nil, fmt.Errorf("host selector %q matches no hosts", expr)
+	}
+	return buildGroupInventory(matched), h.hostCerts(matched), nil
+}
+
+// hostCerts maps the names of hosts to their decrypted SSH certs, for the
+// hosts that have one.
+func (h *RunsHandler) hostCerts(hosts []*models.Host) map[string][]byte {
+	return hostCertsFor(h.sshCerts, hosts)
+}
+
+func hostCertsFor(sshCerts *store.SSHCertStore, hosts []*models.Host) map[string][]byte {
+	certs := map[string][]byte{}
+	for _, host := range hosts {
+		if host.SSHCertID == nil {
+			continue
+		}
+		if cert, _ := sshCerts.GetDecryptedCert(*host.SSHCertID); len(cert) > 0 {
+			certs[host.Name] = cert
+		}
+	}
+	return certs
+}
+
+// inventoryHostNames lists the host names in a run's stored inventory.
+func inventoryHostNames(inventory string) []string {
+	var names []string
+	if detectInventoryFormat("", []byte(inventory)) == "yaml" {
+		parsed, _, _ := parseInventoryYAML([]byte(inventory))
+		for _, host := range parsed {
+			names = append(names, host.Name)
+		}
+		return names
+	}
+	// Runs queued before inventories were rendered as YAML.
+	for _, line := range strings.Split(inventory, "\n") {
+		if f := strings.Fields(line); len(f) > 0 && !strings.HasPrefix(f[0], "[") {
+			names = append(names, f[0])
+		}
+	}
+	return names
+}
+
+// inventoryHostCerts finds the SSH certs for a run's stored inventory, such
+// as a server-group member run's: the certs of the hosts it names.
+func (h *RunsHandler) inventoryHostCerts(inventory string) map[string][]byte {
+	names := map[string]bool{}
+	for _, name := range inventoryHostNames(inventory) {
+		names[name] = true
+	}
+	hosts, err := h.hosts.List()
+	if err != nil {
+		return nil
+	}
+	var named []*models.Host
+	for _, host := range hosts {
+		if names[host.Name] {
+			named = append(named, host)
+		}
+	}
+	return h.hostCerts(named)
+}
+
+// buildGroupInventory creates a YAML inventory with every host of a
+// single-mode server group or host selector under all.hosts, so one
+// ansible-playbook run can coordinate across them. Each host is written as
+// single-host runs write it, with ansible_host and its host vars. A later
+// host with the same name as an earlier one is left out.
+func buildGroupInventory(members []*models.Host) string {
+	all := &yamlInventoryGroup{Hosts: map[string]map[string]interface{}{}}
+	for _, m := range members {
+		if _, seen := all.Hosts[m.Name]; seen {
+			continue
+		}
+		all.Hosts[m.Name] = inventoryHostVars(m.Name, m.Address, m.Vars)
 	}
-	return "[all]\n" + entry + "\n"
+	return renderYAMLInventory(all)
 }
 
 // TriggerScheduledRun is the callback invoked by the scheduler on each cron tick.
+// Scheduled runs use the form's default run options; the schedule check/diff
+// flags additionally turn a scheduled run into a dry run.
 func (h *RunsHandler) TriggerScheduledRun(form *models.Form, variables map[string]interface{}) {
-	runID, batchID, _, err := h.launchFormRuns(form, variables)
+	opts := form.RunOptions
+	opts.Check = opts.Check || form.ScheduleCheckMode
+	opts.Diff = opts.Diff || form.ScheduleDiffMode
+	runID, batchID, _, err := h.launchFormRuns(form, variables, opts)
 	if err != nil {
 		log.Printf("[scheduler] failed to launch runs for form %s: %v", form.ID, err)
 		return
 	}
 	if batchID != "" {
-		log.Printf("[scheduler] batch run %s started for form %s", batchID, form.ID)
+		log.Printf("[scheduler] batch run %s queued for form %s", batchID, form.ID)
 	} else {
-		log.Printf("[scheduler] run %s started for form %s", runID, form.ID)
+		log.Printf("[scheduler] run %s queued for form %s", runID, form.ID)
 	}
 }
@@ -95,11 +95,7 @@ func (h *ServerGroupsHandler) GetMembers(c *gin.Context) {
 		return
 	}
 	if members == nil {
-		members = []*models.Server{}
-	}
-	// Blank out SSH private keys before returning
-	for _, s := range members {
-		s.SSHPrivateKey = ""
+		members = []*models.Host{}
 	}
 	c.JSON(http.StatusOK, members)
 }
@@ -107,13 +103,18 @@ func (h *ServerGroupsHandler) GetMembers(c *gin.Context) {
 func (h *ServerGroupsHandler) SetMembers(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
+		HostIDs   []string `json:"host_ids"`
 		ServerIDs []string `json:"server_ids"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.groups.SetMembers(id, req.ServerIDs); err != nil {
+	if len(req.ServerIDs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "server group members are hosts now: send host_ids instead of server_ids"})
+		return
+	}
+	if err := h.groups.SetMembers(id, req.HostIDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
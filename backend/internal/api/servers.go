@@ -1,7 +1,12 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/models"
 	"github.com/brettjrea/ansible-frontend/internal/runner"
@@ -27,6 +32,9 @@ func (h *ServersHandler) List(c *gin.Context) {
 	if list == nil {
 		list = []*models.Server{}
 	}
+	for _, sv := range list {
+		sv.HostKeyFingerprint = runner.Fingerprint(sv.HostKey)
+	}
 	c.JSON(http.StatusOK, list)
 }
 
@@ -37,6 +45,7 @@ func (h *ServersHandler) Get(c *gin.Context) {
 		return
 	}
 	sv.SSHPrivateKey = "" // never expose key in GET
+	sv.HostKeyFingerprint = runner.Fingerprint(sv.HostKey)
 	c.JSON(http.StatusOK, sv)
 }
 
@@ -49,13 +58,27 @@ func (h *ServersHandler) Create(c *gin.Context) {
 		SSHPrivateKey        string `json:"ssh_private_key"`
 		PreCommand           string `json:"pre_command"`
 		ExecutionEnvironment string `json:"execution_environment"`
+		RunnerType           string `json:"runner_type"`
+		HostKey              string `json:"host_key"`
+		HostCAKeys           string `json:"host_ca_keys"`
+		JumpServerID         string `json:"jump_server_id"`
+		MaxConcurrentRuns    int    `json:"max_concurrent_runs"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// SSH fields required when not using an execution environment.
-	if req.ExecutionEnvironment == "" {
+	if err := runner.ValidateHostKeys(req.HostKey, req.HostCAKeys); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MaxConcurrentRuns < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNegativeConcurrency})
+		return
+	}
+	req.RunnerType = defaultRunnerType(req.RunnerType, req.ExecutionEnvironment)
+	switch req.RunnerType {
+	case runner.KindSSH:
 		if req.Host == "" || req.Username == "" || req.SSHPrivateKey == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "host, username, and ssh_private_key are required for SSH servers"})
 			return
@@ -63,13 +86,31 @@ func (h *ServersHandler) Create(c *gin.Context) {
 		if req.Port == 0 {
 			req.Port = 22
 		}
+	case runner.KindKubernetes:
+		if req.ExecutionEnvironment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "execution_environment is required for Kubernetes runners"})
+			return
+		}
+	case runner.KindLocal:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "runner_type must be ssh, kubernetes or local"})
+		return
+	}
+	var jumpServerID *string
+	if req.RunnerType == runner.KindSSH {
+		var err error
+		if jumpServerID, err = h.validateJumpServer("", req.JumpServerID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	sv, err := h.servers.Create(req.Name, req.Host, req.Port, req.Username, req.SSHPrivateKey, req.PreCommand, req.ExecutionEnvironment)
+	sv, err := h.servers.Create(req.Name, req.Host, req.Port, req.Username, req.SSHPrivateKey, req.PreCommand, req.ExecutionEnvironment, req.RunnerType, strings.TrimSpace(req.HostKey), req.HostCAKeys, jumpServerID, req.MaxConcurrentRuns)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	sv.HostKeyFingerprint = runner.Fingerprint(sv.HostKey)
 	uid, uname := auditUser(c)
 	h.audit.Log(uid, uname, "create", "server", sv.ID, "", c.ClientIP())
 	c.JSON(http.StatusCreated, sv)
@@ -85,12 +126,37 @@ func (h *ServersHandler) Update(c *gin.Context) {
 		SSHPrivateKey        string `json:"ssh_private_key"`
 		PreCommand           string `json:"pre_command"`
 		ExecutionEnvironment string `json:"execution_environment"`
+		RunnerType           string `json:"runner_type"`
+		JumpServerID         string `json:"jump_server_id"`
+		MaxConcurrentRuns    int    `json:"max_concurrent_runs"`
+		// Omitted host key fields keep the stored value; "" clears it.
+		HostKey    *string `json:"host_key"`
+		HostCAKeys *string `json:"host_ca_keys"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if req.ExecutionEnvironment == "" {
+	if req.MaxConcurrentRuns < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNegativeConcurrency})
+		return
+	}
+	if req.HostKey != nil {
+		*req.HostKey = strings.TrimSpace(*req.HostKey)
+		if err := runner.ValidateHostKeys(*req.HostKey, ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.HostCAKeys != nil {
+		if err := runner.ValidateHostKeys("", *req.HostCAKeys); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.RunnerType = defaultRunnerType(req.RunnerType, req.ExecutionEnvironment)
+	switch req.RunnerType {
+	case runner.KindSSH:
 		if req.Host == "" || req.Username == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "host and username are required for SSH servers"})
 			return
@@ -98,14 +164,32 @@ func (h *ServersHandler) Update(c *gin.Context) {
 		if req.Port == 0 {
 			req.Port = 22
 		}
+	case runner.KindKubernetes:
+		if req.ExecutionEnvironment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "execution_environment is required for Kubernetes runners"})
+			return
+		}
+	case runner.KindLocal:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "runner_type must be ssh, kubernetes or local"})
+		return
+	}
+	var jumpServerID *string
+	if req.RunnerType == runner.KindSSH {
+		var err error
+		if jumpServerID, err = h.validateJumpServer(id, req.JumpServerID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	sv, err := h.servers.Update(id, req.Name, req.Host, req.Port, req.Username, req.SSHPrivateKey, req.PreCommand, req.ExecutionEnvironment)
+	sv, err := h.servers.Update(id, req.Name, req.Host, req.Port, req.Username, req.SSHPrivateKey, req.PreCommand, req.ExecutionEnvironment, req.RunnerType, req.HostKey, req.HostCAKeys, jumpServerID, req.MaxConcurrentRuns)
 	if err != nil || sv == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
 		return
 	}
 	sv.SSHPrivateKey = ""
+	sv.HostKeyFingerprint = runner.Fingerprint(sv.HostKey)
 	uid, uname := auditUser(c)
 	h.audit.Log(uid, uname, "update", "server", id, "", c.ClientIP())
 	c.JSON(http.StatusOK, sv)
@@ -129,16 +213,50 @@ func (h *ServersHandler) Test(c *gin.Context) {
 		return
 	}
 
-	if sv.ExecutionEnvironment != "" {
+	switch sv.RunnerType {
+	case runner.KindKubernetes:
 		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Execution Environment: " + sv.ExecutionEnvironment + " (connection test not applicable)"})
 		return
+	case runner.KindLocal:
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+		defer cancel()
+		out, err := runner.LocalVersion(ctx)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Local runner: " + out})
+		return
 	}
 
-	client, err := runner.Connect(sv.Host, sv.Port, sv.Username, sv.SSHPrivateKey)
+	chain, err := serverChain(h.servers, sv)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	// Any hop with nothing pinned is trusted on first use: the key it offers
+	// now is pinned once the whole chain has logged in.
+	hops := serverHops(chain)
+	offered := make([]string, len(chain))
+	for i := range hops {
+		if chain[i].HostKey == "" {
+			i := i
+			hops[i].HostKeys.OnUnknown = func(hostKey string) { offered[i] = hostKey }
+		}
+	}
+	client, err := runner.Connect(hops...)
+	if err != nil {
+		resp := gin.H{"success": false, "message": err.Error()}
+		var hkErr *runner.HostKeyError
+		var hopErr *runner.HopError
+		if errors.As(err, &hkErr) && errors.As(err, &hopErr) {
+			resp["offered_server_id"] = chain[hopErr.Index].ID
+			resp["offered_host_key"] = hkErr.Offered
+			resp["offered_fingerprint"] = runner.Fingerprint(hkErr.Offered)
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
 	defer client.Close()
 
 	out, err := client.RunCommand("echo ok")
@@ -146,5 +264,118 @@ func (h *ServersHandler) Test(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "SSH connection successful: " + out})
+	msg := strings.TrimSpace("SSH connection successful: " + out)
+	if len(chain) > 1 {
+		msg += fmt.Sprintf(" (via %d jump host(s))", len(chain)-1)
+	}
+	uid, uname := auditUser(c)
+	for i, key := range offered {
+		if key == "" {
+			continue
+		}
+		if err := h.servers.SetHostKey(chain[i].ID, key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		fp := runner.Fingerprint(key)
+		h.audit.Log(uid, uname, "trust-host-key", "server", chain[i].ID, fp, c.ClientIP())
+		msg += fmt.Sprintf(". Host key %s of %q trusted on first use", fp, chain[i].Name)
+		chain[i].HostKey = key
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": msg, "host_key_fingerprint": runner.Fingerprint(sv.HostKey)})
+}
+
+// maxJumpHops bounds a jump host chain, which also stops cycles that slip
+// past validation (e.g. from concurrent edits) from looping forever.
+const maxJumpHops = 8
+
+// serverChain resolves sv's jump hosts: the result lists them outermost
+// first and ends with sv itself.
+func serverChain(servers *store.ServerStore, sv *models.Server) ([]*models.Server, error) {
+	chain := []*models.Server{sv}
+	seen := map[string]bool{sv.ID: true}
+	for cur := sv; cur.JumpServerID != nil; {
+		if len(chain) > maxJumpHops {
+			return nil, fmt.Errorf("jump host chain for %q is longer than %d hops", sv.Name, maxJumpHops)
+		}
+		next, err := servers.Get(*cur.JumpServerID)
+		if err != nil {
+			return nil, fmt.Errorf("load jump host for %q: %w", cur.Name, err)
+		}
+		if next == nil {
+			return nil, fmt.Errorf("jump host for %q no longer exists", cur.Name)
+		}
+		if seen[next.ID] {
+			return nil, fmt.Errorf("jump host chain for %q loops back to %q", sv.Name, next.Name)
+		}
+		seen[next.ID] = true
+		chain = append([]*models.Server{next}, chain...)
+		cur = next
+	}
+	return chain, nil
+}
+
+// serverHops converts a resolved chain into SSH hops.
+func serverHops(chain []*models.Server) []runner.Hop {
+	hops := make([]runner.Hop, len(chain))
+	for i, sv := range chain {
+		hops[i] = runner.Hop{
+			Name:          sv.Name,
+			Host:          sv.Host,
+			Port:          sv.Port,
+			Username:      sv.Username,
+			SSHPrivateKey: sv.SSHPrivateKey,
+			HostKeys:      serverHostKeys(sv),
+		}
+	}
+	return hops
+}
+
+// validateJumpServer checks that jumpID can be the jump host of the server
+// with the given id ("" for a new server) and returns it as a nullable ID.
+func (h *ServersHandler) validateJumpServer(id, jumpID string) (*string, error) {
+	if jumpID == "" {
+		return nil, nil
+	}
+	if jumpID == id {
+		return nil, fmt.Errorf("a server cannot be its own jump host")
+	}
+	jump, err := h.servers.Get(jumpID)
+	if err != nil || jump == nil {
+		return nil, fmt.Errorf("jump host not found")
+	}
+	if jump.RunnerType != runner.KindSSH {
+		return nil, fmt.Errorf("jump host %q is not an SSH server", jump.Name)
+	}
+	chain, err := serverChain(h.servers, jump)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) >= maxJumpHops {
+		return nil, fmt.Errorf("jump host chain would be longer than %d hops", maxJumpHops)
+	}
+	for _, hop := range chain {
+		if hop.ID == id {
+			return nil, fmt.Errorf("jump host %q already connects through this server", jump.Name)
+		}
+	}
+	return &jumpID, nil
+}
+
+// serverHostKeys is the host key trust configuration for an SSH job runner.
+func serverHostKeys(sv *models.Server) runner.HostKeys {
+	return runner.HostKeys{Pinned: sv.HostKey, CAKeys: sv.HostCAKeys}
+}
+
+// defaultRunnerType fills in the runner backend for clients that predate
+// runner_type: a server with an execution environment image runs on
+// Kubernetes, anything else over SSH.
+func defaultRunnerType(runnerType, executionEnvironment string) string {
+	if runnerType != "" {
+		return runnerType
+	}
+	if executionEnvironment != "" {
+		return runner.KindKubernetes
+	}
+	return runner.KindSSH
 }
@@ -20,17 +20,27 @@ type Server struct {
 	SSHPrivateKey        string    `json:"ssh_private_key,omitempty" db:"ssh_private_key"`
 	PreCommand           string    `json:"pre_command" db:"pre_command"`
 	ExecutionEnvironment string    `json:"execution_environment" db:"execution_environment"`
+	RunnerType           string    `json:"runner_type" db:"runner_type"`                 // ssh | kubernetes | local
+	HostKey              string    `json:"host_key" db:"host_key"`                       // pinned SSH host key, authorized_keys format
+	HostKeyFingerprint   string    `json:"host_key_fingerprint" db:"-"`                  // SHA256 fingerprint of HostKey
+	HostCAKeys           string    `json:"host_ca_keys" db:"host_ca_keys"`               // trusted host CAs, see runner.HostKeys
+	JumpServerID         *string   `json:"jump_server_id" db:"jump_server_id"`           // SSH jump host (another server), may itself have one
+	MaxConcurrentRuns    int       `json:"max_concurrent_runs" db:"max_concurrent_runs"` // runs at once on this runner; 0 = no limit
 	CreatedAt            time.Time `json:"created_at" db:"created_at"`
 }
 
 type Playbook struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	RepoURL     string    `json:"repo_url"`
-	Branch      string    `json:"branch"`
-	Token       string    `json:"token,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	RepoURL     string `json:"repo_url"`
+	Branch      string `json:"branch"`
+	Token       string `json:"token,omitempty"`
+	// GalaxyServerURL is an optional Galaxy / Automation Hub server used when
+	// installing requirements.yml dependencies; public Galaxy is the fallback.
+	GalaxyServerURL string    `json:"galaxy_server_url"`
+	HasGalaxyToken  bool      `json:"has_galaxy_token"` // token itself is never returned
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 type FormField struct {
@@ -57,15 +67,81 @@ type Vault struct {
 }
 
 // Host represents an Ansible inventory host — the machine a playbook targets.
-// Vars are stored as a key-value map and written to the inventory at run time.
+// Vars hold any JSON value (strings, numbers, booleans, lists and maps) and
+// are written to a YAML inventory at run time, so their types survive.
 type Host struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Address     string            `json:"address"` // IP or FQDN used in the inventory
-	Description string            `json:"description"`
-	SSHCertID   *string           `json:"ssh_cert_id,omitempty"` // optional SSH cert for ansible_ssh_private_key_file
-	Vars        map[string]string `json:"vars"`                  // ansible host_vars
-	CreatedAt   time.Time         `json:"created_at"`
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name"`
+	Address         string                 `json:"address"` // IP or FQDN used in the inventory
+	Description     string                 `json:"description"`
+	SSHCertID       *string                `json:"ssh_cert_id,omitempty"` // optional SSH cert for ansible_ssh_private_key_file
+	Vars            map[string]interface{} `json:"vars"`                  // ansible host_vars
+	Labels          map[string]string      `json:"labels"`                // free-form key=value labels for host selectors
+	SourceID        *string                `json:"source_id"`             // inventory source that last synced the host; nil if added by hand
+	LastCheckStatus string                 `json:"last_check_status"`     // ok | unreachable | failed; empty if never checked
+	LastCheckAt     *time.Time             `json:"last_check_at"`
+	LastCheckError  string                 `json:"last_check_error"` // Ansible's message when the last check was not ok
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// InventoryGroup is an Ansible inventory group of hosts with its own group
+// vars and child groups. It is separate from ServerGroup, which groups runners.
+// Vars hold any JSON value, like Host.Vars.
+type InventoryGroup struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"` // Ansible group name
+	Description string                 `json:"description"`
+	Vars        map[string]interface{} `json:"vars"` // written as [name:vars]
+	HostIDs     []string               `json:"host_ids"`
+	ChildIDs    []string               `json:"child_ids"` // written as [name:children]
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// InventorySource is a dynamic inventory whose hosts are synced into the
+// Hosts table, on a schedule or on demand.
+type InventorySource struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"` // git | runner | http
+	// PlaybookID and Path name an inventory file in a playbook source (git).
+	// For runner sources Path is the inventory ansible-inventory reads on the
+	// runner: a file, directory or inventory plugin config.
+	PlaybookID     *string    `json:"playbook_id"`
+	Path           string     `json:"path"`
+	ServerID       *string    `json:"server_id"` // runner
+	URL            string     `json:"url"`       // http: returns ansible-inventory --list JSON
+	HasToken       bool       `json:"has_token"` // http bearer token; the token itself is never returned
+	ScheduleCron   string     `json:"schedule_cron"`
+	DeleteMissing  bool       `json:"delete_missing"` // delete synced hosts the source no longer lists
+	LastSyncAt     *time.Time `json:"last_sync_at"`
+	LastSyncStatus string     `json:"last_sync_status"` // "" | running | success | failed
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// InventorySync is one sync of an inventory source.
+type InventorySync struct {
+	ID         string     `json:"id"`
+	SourceID   string     `json:"source_id"`
+	Trigger    string     `json:"trigger"` // manual | schedule
+	Status     string     `json:"status"`  // running | success | failed
+	Created    []string   `json:"created"` // host names
+	Updated    []string   `json:"updated"`
+	Deleted    []string   `json:"deleted"`
+	Skipped    []string   `json:"skipped"` // hosts synced by another source, with the reason
+	Message    string     `json:"message"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// HostFacts is the cached ansible_facts of a host, from the last setup or
+// gather_facts result seen for it.
+type HostFacts struct {
+	HostID     string                 `json:"host_id"`
+	Facts      map[string]interface{} `json:"facts"`
+	Source     string                 `json:"source"` // run | gather
+	RunID      *string                `json:"run_id"` // run the facts came from, for source run
+	GatheredAt time.Time              `json:"gathered_at"`
 }
 
 type SSHCert struct {
@@ -84,26 +160,39 @@ type ServerGroup struct {
 }
 
 type Form struct {
-	ID              string      `json:"id" db:"id"`
-	Name            string      `json:"name" db:"name"`
-	Description     string      `json:"description" db:"description"`
-	PlaybookID      string      `json:"playbook_id" db:"playbook_id"`
-	PlaybookPath    string      `json:"playbook_path" db:"playbook_path"`
-	ServerID        *string     `json:"server_id" db:"server_id"`
-	HostID          *string     `json:"host_id" db:"host_id"`
-	ServerGroupID   *string     `json:"server_group_id" db:"server_group_id"`
-	VaultID         *string     `json:"vault_id" db:"vault_id"`
-	IsQuickAction   bool        `json:"is_quick_action" db:"is_quick_action"`
-	ImageName       string      `json:"image_name" db:"image_name"`
-	ScheduleCron    string      `json:"schedule_cron" db:"schedule_cron"`
-	ScheduleEnabled bool        `json:"schedule_enabled" db:"schedule_enabled"`
-	WebhookToken    string      `json:"webhook_token" db:"webhook_token"`
-	NotifyWebhook   string      `json:"notify_webhook" db:"notify_webhook"`
-	NotifyEmail     string      `json:"notify_email" db:"notify_email"`
-	Status          string      `json:"status" db:"status"`
-	Fields          []FormField `json:"fields,omitempty" db:"-"`
-	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	ID               string  `json:"id" db:"id"`
+	Name             string  `json:"name" db:"name"`
+	Description      string  `json:"description" db:"description"`
+	PlaybookID       string  `json:"playbook_id" db:"playbook_id"`
+	PlaybookPath     string  `json:"playbook_path" db:"playbook_path"`
+	ServerID         *string `json:"server_id" db:"server_id"`
+	HostID           *string `json:"host_id" db:"host_id"`
+	ServerGroupID    *string `json:"server_group_id" db:"server_group_id"`
+	InventoryGroupID *string `json:"inventory_group_id" db:"inventory_group_id"`
+	HostSelector     string  `json:"host_selector" db:"host_selector"` // label selector resolved to hosts when a run starts
+	VaultID          *string `json:"vault_id" db:"vault_id"`
+	IsQuickAction    bool    `json:"is_quick_action" db:"is_quick_action"`
+	ImageName        string  `json:"image_name" db:"image_name"`
+	ScheduleCron     string  `json:"schedule_cron" db:"schedule_cron"`
+	ScheduleEnabled  bool    `json:"schedule_enabled" db:"schedule_enabled"`
+	// Scheduled runs are launched with --check / --diff when set.
+	ScheduleCheckMode bool `json:"schedule_check_mode" db:"schedule_check_mode"`
+	ScheduleDiffMode  bool `json:"schedule_diff_mode" db:"schedule_diff_mode"`
+	// RunOptions are the defaults for every run of the form. Viewers may only
+	// change the options named in OverridableOptions; editors may change any.
+	RunOptions         RunOptions  `json:"run_options" db:"run_options"`
+	OverridableOptions []string    `json:"overridable_options" db:"overridable_options"`
+	MaxConcurrentRuns  int         `json:"max_concurrent_runs" db:"max_concurrent_runs"` // runs of this form at once; 0 = no limit
+	MaxRuntimeMinutes  int         `json:"max_runtime_minutes" db:"max_runtime_minutes"` // runs are stopped as timed_out after this; 0 = no limit
+	Rollout            Rollout     `json:"rollout" db:"rollout"`                         // server-group targets only
+	GroupRunMode       string      `json:"group_run_mode" db:"group_run_mode"`           // per_member | single, server-group targets only
+	WebhookToken       string      `json:"webhook_token" db:"webhook_token"`
+	NotifyWebhook      string      `json:"notify_webhook" db:"notify_webhook"`
+	NotifyEmail        string      `json:"notify_email" db:"notify_email"`
+	Status             string      `json:"status" db:"status"`
+	Fields             []FormField `json:"fields,omitempty" db:"-"`
+	CreatedAt          time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 type AuditLog struct {
@@ -119,14 +208,178 @@ type AuditLog struct {
 }
 
 type Run struct {
-	ID         string     `json:"id" db:"id"`
-	FormID     *string    `json:"form_id" db:"form_id"`
-	PlaybookID string     `json:"playbook_id" db:"playbook_id"`
-	ServerID   string     `json:"server_id" db:"server_id"`
-	Variables  string     `json:"variables" db:"variables"`
-	Status     string     `json:"status" db:"status"`
-	Output     string     `json:"output" db:"output"`
-	BatchID    *string    `json:"batch_id" db:"batch_id"`
-	StartedAt  *time.Time `json:"started_at" db:"started_at"`
-	FinishedAt *time.Time `json:"finished_at" db:"finished_at"`
+	ID            string         `json:"id" db:"id"`
+	FormID        *string        `json:"form_id" db:"form_id"`
+	PlaybookID    string         `json:"playbook_id" db:"playbook_id"`
+	ServerID      string         `json:"server_id" db:"server_id"`
+	Variables     string         `json:"variables" db:"variables"`
+	Status        string         `json:"status" db:"status"`
+	Output        string         `json:"output" db:"output"`
+	BatchID       *string        `json:"batch_id" db:"batch_id"`
+	BatchPhase    int            `json:"batch_phase,omitempty" db:"batch_phase"` // rollout batch within BatchID, from 1
+	CheckMode     bool           `json:"check_mode" db:"check_mode"`             // dry run (--check)
+	DiffMode      bool           `json:"diff_mode" db:"diff_mode"`               // --diff
+	Options       RunOptions     `json:"options" db:"options"`
+	CancelledBy   *string        `json:"cancelled_by" db:"cancelled_by"`   // who cancelled it (status "cancelled")
+	ParentRunID   *string        `json:"parent_run_id" db:"parent_run_id"` // the run this one relaunched
+	Inventory     string         `json:"-" db:"inventory"`                 // fixed inventory of server-group member runs
+	Summary       *RunSummary    `json:"summary"`                          // nil until a PLAY RECAP is recorded
+	Recap         []RunHostStats `json:"recap,omitempty"`                  // only on single-run reads
+	QueuePosition int            `json:"queue_position,omitempty"`         // 1-based place in the run queue while pending
+	StartedAt     *time.Time     `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time     `json:"finished_at" db:"finished_at"`
+}
+
+// QueueEntry is a run waiting in the run queue, or claimed by a worker.
+type QueueEntry struct {
+	RunID      string     `json:"run_id"`
+	FormID     *string    `json:"form_id"`
+	FormName   string     `json:"form_name"`
+	ServerID   string     `json:"server_id"`
+	BatchID    *string    `json:"batch_id"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`           // times claimed, including restarts
+	Position   int        `json:"position,omitempty"` // 1-based place among queued runs
+	Blocked    string     `json:"blocked,omitempty"`  // why a queued run can't start yet
+	EnqueuedAt time.Time  `json:"enqueued_at"`
+	ClaimedAt  *time.Time `json:"claimed_at"`
+}
+// RunHostStats is one host's line of a run's PLAY RECAP.
+type RunHostStats struct {
+	Host        string `json:"host"`
+	OK          int    `json:"ok"`
+	Changed     int    `json:"changed"`
+	Unreachable int    `json:"unreachable"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	Rescued     int    `json:"rescued"`
+	Ignored     int    `json:"ignored"`
+}
+
+// RunSummary totals a run's PLAY RECAP across hosts. The *Hosts fields
+// count hosts with at least one task in that state.
+type RunSummary struct {
+	Hosts            int `json:"hosts"`
+	OK               int `json:"ok"`
+	Changed          int `json:"changed"`
+	Unreachable      int `json:"unreachable"`
+	Failed           int `json:"failed"`
+	Skipped          int `json:"skipped"`
+	Rescued          int `json:"rescued"`
+	Ignored          int `json:"ignored"`
+	ChangedHosts     int `json:"changed_hosts"`
+	FailedHosts      int `json:"failed_hosts"`
+	UnreachableHosts int `json:"unreachable_hosts"`
+}
+
+// RunOptions are the ansible-playbook command-line options chosen for a run.
+// Zero values leave ansible-playbook's defaults in place.
+type RunOptions struct {
+	Check       bool   `json:"check"`         // --check
+	Diff        bool   `json:"diff"`          // --diff
+	Limit       string `json:"limit"`         // --limit host pattern
+	Tags        string `json:"tags"`          // --tags, comma-separated
+	SkipTags    string `json:"skip_tags"`     // --skip-tags, comma-separated
+	StartAtTask string `json:"start_at_task"` // --start-at-task
+	Forks       int    `json:"forks"`         // --forks; 0 uses the ansible.cfg default
+	Verbosity   int    `json:"verbosity"`     // 0-4, -v to -vvvv
 }
+
+// Rollout controls how a server-group form's member runs are spread out: the
+// members run BatchSize at a time, in group order, and each further batch
+// starts once the previous one has finished.
+type Rollout struct {
+	BatchSize       string `json:"batch_size"`       // members per batch: a count ("5") or a percentage ("25%"); "" runs all at once
+	PauseSeconds    int    `json:"pause_seconds"`    // wait between batches
+	MaxFailPercent  *int   `json:"max_fail_percent"` // stop the remaining batches once more than this % of finished runs failed; nil never stops
+	RequireApproval bool   `json:"require_approval"` // wait for POST /batches/:id/approve before each further batch
+}
+
+// Batch is the set of runs launched for a server-group form, one per member,
+// executed in rollout batches numbered from 1.
+type Batch struct {
+	ID          string         `json:"id"`
+	FormID      *string        `json:"form_id"`
+	FormName    string         `json:"form_name"`
+	Rollout     Rollout        `json:"rollout"` // the form's settings when the batch was launched
+	Status      string         `json:"status"`  // running, paused, awaiting_approval, cancelling, success, failed, stopped or cancelled
+	Phase       int            `json:"phase"`   // the batch running or last finished
+	Phases      int            `json:"phases"`
+	Message     string         `json:"message"`       // why the rollout is waiting or stopped
+	NextPhaseAt *time.Time     `json:"next_phase_at"` // when a paused rollout resumes
+	Members     []*BatchMember `json:"members,omitempty"`
+	Counts      map[string]int `json:"counts,omitempty"` // member runs per run status
+	Duration    float64        `json:"duration_seconds"` // from launch to finish, or until now
+	CreatedAt   time.Time      `json:"created_at"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+}
+
+// BatchMember is one server-group member's run within a Batch.
+type BatchMember struct {
+	RunID      string      `json:"run_id"`
+	Host       string      `json:"host"`
+	Phase      int         `json:"phase"`
+	Status     string      `json:"status"`
+	Summary    *RunSummary `json:"summary"`
+	StartedAt  *time.Time  `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+}
+
+// RunPlay is one play of a run, recorded from the events callback plugin.
+type RunPlay struct {
+	ID        string     `json:"id"`
+	RunID     string     `json:"run_id"`
+	Name      string     `json:"name"`
+	StartedAt time.Time  `json:"started_at"`
+	Tasks     []*RunTask `json:"tasks"`
+}
+
+// RunTask is one task (or handler) of a play.
+type RunTask struct {
+	ID        string           `json:"id"`
+	RunID     string           `json:"run_id"`
+	PlayID    string           `json:"play_id"`
+	Name      string           `json:"name"`
+	Action    string           `json:"action"` // module name
+	Handler   bool             `json:"handler"`
+	StartedAt time.Time        `json:"started_at"`
+	Results   []*RunHostResult `json:"results"`
+}
+
+// RunHostResult is the outcome of one task on one host.
+type RunHostResult struct {
+	ID           string    `json:"id"`
+	RunID        string    `json:"run_id"`
+	TaskID       string    `json:"task_id"`
+	Host         string    `json:"host"`
+	Status       string    `json:"status"` // ok | changed | failed | skipped | unreachable
+	Action       string    `json:"action"`
+	Msg          string    `json:"msg"`
+	Duration     *float64  `json:"duration"` // seconds
+	IgnoreErrors bool      `json:"ignore_errors"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RunHostSummary totals a run's task results for one host.
+type RunHostSummary struct {
+	Host        string           `json:"host"`
+	OK          int              `json:"ok"`
+	Changed     int              `json:"changed"`
+	Failed      int              `json:"failed"`
+	Skipped     int              `json:"skipped"`
+	Unreachable int              `json:"unreachable"`
+	Failures    []RunHostFailure `json:"failures"`
+}
+
+// RunHostFailure is a failed or unreachable task result on a host.
+type RunHostFailure struct {
+	TaskID       string `json:"task_id"`
+	Task         string `json:"task"`
+	Status       string `json:"status"`
+	Msg          string `json:"msg"`
+	IgnoreErrors bool   `json:"ignore_errors"`
+}
+
+// RunOptionNames lists the RunOptions JSON names, in display order. Form
+// overridable_options entries must be one of these.
+var RunOptionNames = []string{"check", "diff", "limit", "tags", "skip_tags", "start_at_task", "forks", "verbosity"}
@@ -121,6 +121,54 @@ func Send(webhookURL, emailTo, runID, status, formName string) {
 	}
 }
 
+// BatchRun is one member run in a batch notification.
+type BatchRun struct {
+	RunID  string `json:"run_id"`
+	Host   string `json:"host"`
+	Status string `json:"status"`
+}
+
+type batchWebhookPayload struct {
+	BatchID  string         `json:"batch_id"`
+	Status   string         `json:"status"`
+	FormName string         `json:"form_name"`
+	Counts   map[string]int `json:"counts"`
+	Runs     []BatchRun     `json:"runs"`
+	Time     string         `json:"time"`
+}
+
+// SendBatch fires one webhook POST and/or email summarising a finished batch
+// of server-group member runs, in place of one notification per member.
+func SendBatch(webhookURL, emailTo, batchID, status, formName string, counts map[string]int, runs []BatchRun) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if webhookURL != "" {
+		postWebhook(webhookURL, batchWebhookPayload{
+			BatchID:  batchID,
+			Status:   status,
+			FormName: formName,
+			Counts:   counts,
+			Runs:     runs,
+			Time:     now,
+		})
+	}
+	if emailTo != "" {
+		cfg := GetConfig()
+		subject := fmt.Sprintf("[Automation Hub] %s: batch %s (%d hosts)", formName, status, len(runs))
+		var body strings.Builder
+		fmt.Fprintf(&body, "Batch ID: %s\nForm: %s\nStatus: %s\nTime: %s\n\n", batchID, formName, status, now)
+		for _, st := range []string{"success", "failed", "timed_out", "cancelled", "running", "pending"} {
+			if counts[st] > 0 {
+				fmt.Fprintf(&body, "%s: %d\n", st, counts[st])
+			}
+		}
+		body.WriteString("\n")
+		for _, r := range runs {
+			fmt.Fprintf(&body, "%-10s %s (run %s)\n", r.Status, r.Host, r.RunID)
+		}
+		sendEmail(cfg, emailTo, subject, body.String())
+	}
+}
+
 func sendWebhook(url, runID, status, formName string) {
 	payload := webhookPayload{
 		RunID:    runID,
@@ -128,6 +176,10 @@ func sendWebhook(url, runID, status, formName string) {
 		FormName: formName,
 		Time:     time.Now().UTC().Format(time.RFC3339),
 	}
+	postWebhook(url, payload)
+}
+
+func postWebhook(url string, payload interface{}) {
 	body, _ := json.Marshal(payload)
 	resp, err := http.Post(url, "application/json", bytes.NewReader(body)) //nolint:noctx
 	if err != nil {
@@ -0,0 +1,36 @@
+package runner
+
+import "fmt"
+
+// AdHoc is an ad-hoc module run, `ansible <pattern> -m <module> -a <args>`,
+// used for host checks such as gathering facts or pinging. It goes through
+// the same backends and workspace as playbook runs, with an empty source
+// (see AdHocSource), so SSH, Kubernetes and local runners all support it.
+type AdHoc struct {
+	Pattern string // host pattern; "all" when empty
+	Module  string
+	Args    string // passed with -a when set
+}
+
+// AdHocSource is the empty source an ad-hoc RunSpec ships in place of a
+// playbook checkout.
+func AdHocSource() (*Source, error) {
+	archive, err := packFiles(nil)
+	if err != nil {
+		return nil, fmt.Errorf("pack ad-hoc source: %w", err)
+	}
+	return &Source{Archive: archive, WorkDir: "."}, nil
+}
+
+// args is the start of the ansible command line for a.
+func (a *AdHoc) args() []string {
+	pattern := a.Pattern
+	if pattern == "" {
+		pattern = "all"
+	}
+	args := []string{"ansible", shellQuote(pattern), "-m", shellQuote(a.Module)}
+	if a.Args != "" {
+		args = append(args, "-a", shellQuote(a.Args))
+	}
+	return args
+}
@@ -0,0 +1,104 @@
+# Emits one JSON line per play, task and host result on stdout, prefixed with
+# a marker the server strips from the run output and records as structured
+# events. Shipped with every run; see internal/runner/events.go.
+from __future__ import absolute_import, division, print_function
+
+__metaclass__ = type
+
+DOCUMENTATION = '''
+    name: ansible_frontend_events
+    type: notification
+    short_description: JSON-lines run events for ansible-frontend
+    description:
+      - Writes play, task and per-host result events to stdout for the server to record.
+'''
+
+import json
+import sys
+import time
+
+from ansible.plugins.callback import CallbackBase
+
+MARKER = '@@ansible-frontend-event@@ '
+MAX_MSG = 2000
+# Results of these modules carry the host's facts, which the server caches.
+FACT_ACTIONS = ('setup', 'gather_facts')
+# Facts are sent on one output line; past this size the largest top-level
+# facts (per-interface details, devices, mounts...) are left out.
+MAX_FACTS = 256 * 1024
+
+
+def trim_facts(facts):
+    sizes = dict((k, len(json.dumps(v, default=str))) for k, v in facts.items())
+    total = sum(sizes.values())
+    trimmed = dict(facts)
+    for key in sorted(sizes, key=sizes.get, reverse=True):
+        if total <= MAX_FACTS:
+            break
+        del trimmed[key]
+        total -= sizes[key]
+    return trimmed
+
+
+class CallbackModule(CallbackBase):
+    CALLBACK_VERSION = 2.0
+    CALLBACK_TYPE = 'notification'
+    CALLBACK_NAME = 'ansible_frontend_events'
+    # Load without being listed in callbacks_enabled, so the project's own
+    # ansible.cfg callback settings are left alone.
+    CALLBACK_NEEDS_ENABLED = False
+    CALLBACK_NEEDS_WHITELIST = False
+
+    def __init__(self, *args, **kwargs):
+        super(CallbackModule, self).__init__(*args, **kwargs)
+        self._started = {}
+
+    def _emit(self, event, **fields):
+        fields['event'] = event
+        fields['time'] = time.time()
+        sys.stdout.write(MARKER + json.dumps(fields, default=str) + '\n')
+        sys.stdout.flush()
+
+    def v2_playbook_on_play_start(self, play):
+        self._emit('play_start', play_id=str(play._uuid), name=play.get_name().strip())
+
+    def _task_start(self, task, is_handler):
+        self._emit('task_start', task_id=str(task._uuid), name=task.get_name().strip(),
+                   action=task.action, handler=is_handler)
+
+    def v2_playbook_on_task_start(self, task, is_conditional):
+        self._task_start(task, False)
+
+    def v2_playbook_on_handler_task_start(self, task):
+        self._task_start(task, True)
+
+    def v2_runner_on_start(self, host, task):
+        self._started[(host.get_name(), task._uuid)] = time.time()
+
+    def _result(self, status, result, **extra):
+        host = result._host.get_name()
+        task = result._task
+        started = self._started.pop((host, task._uuid), None)
+        res = result._result if isinstance(result._result, dict) else {}
+        msg = res.get('msg') or res.get('stderr') or ''
+        if not isinstance(msg, str):
+            msg = json.dumps(msg, default=str)
+        self._emit('host_result', task_id=str(task._uuid), host=host, status=status,
+                   action=task.action, msg=msg[:MAX_MSG],
+                   duration=(time.time() - started) if started else None, **extra)
+
+    def v2_runner_on_ok(self, result):
+        extra = {}
+        facts = result._result.get('ansible_facts')
+        if facts and result._task.action.split('.')[-1] in FACT_ACTIONS:
+            extra['facts'] = trim_facts(facts)
+        self._result('changed' if result._result.get('changed') else 'ok', result, **extra)
+
+    def v2_runner_on_failed(self, result, ignore_errors=False):
+        self._result('failed', result, ignore_errors=bool(ignore_errors))
+
+    def v2_runner_on_skipped(self, result):
+        self._result('skipped', result)
+
+    def v2_runner_on_unreachable(self, result):
+        self._result('unreachable', result)
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	_ "embed"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// The events callback plugin ships in every run workspace and writes one
+// marker-prefixed JSON line per play, task and host result to stdout. Going
+// through stdout means the SSH, Kubernetes and local backends need nothing
+// beyond the output stream they already have.
+
+//go:embed callback/ansible_frontend_events.py
+var eventsCallback []byte
+
+const (
+	eventsCallbackName = "ansible_frontend_events.py"
+	eventMarker        = "@@ansible-frontend-event@@ "
+)
+
+// Event kinds emitted by the callback plugin.
+const (
+	EventPlayStart  = "play_start"
+	EventTaskStart  = "task_start"
+	EventHostResult = "host_result"
+)
+
+// Event is one line from the events callback plugin. Which fields are set
+// depends on Event; IDs are Ansible's own play and task UUIDs.
+type Event struct {
+	Event        string   `json:"event"`
+	Time         float64  `json:"time"` // Unix seconds
+	PlayID       string   `json:"play_id"`
+	TaskID       string   `json:"task_id"`
+	Name         string   `json:"name"`
+	Action       string   `json:"action"`
+	Handler      bool     `json:"handler"`
+	Host         string   `json:"host"`
+	Status       string   `json:"status"` // ok | changed | failed | skipped | unreachable
+	Msg          string   `json:"msg"`
+	Duration     *float64 `json:"duration"` // seconds, nil if the start wasn't seen
+	IgnoreErrors bool     `json:"ignore_errors"`
+
+	// Facts are the ansible_facts of a successful setup or gather_facts
+	// result, nil for every other result.
+	Facts map[string]interface{} `json:"facts,omitempty"`
+}
+
+// ParseEvent reports whether line carries a callback event. Text before the
+// marker (from output that didn't end its line) is returned as rest so it
+// isn't lost.
+func ParseEvent(line string) (ev *Event, rest string, ok bool) {
+	i := strings.Index(line, eventMarker)
+	if i < 0 {
+		return nil, line, false
+	}
+	ev = &Event{}
+	if err := json.Unmarshal([]byte(line[i+len(eventMarker):]), ev); err != nil {
+		return nil, line, false
+	}
+	return ev, line[:i], true
+}
+
+// callbackPluginPath is the ANSIBLE_CALLBACK_PLUGINS value for a run. It
+// replaces ansible.cfg's callback_plugins, so the run's own directory comes
+// first, followed by the project's configured directories (relative ones
+// resolved from the ansible.cfg's directory, as Ansible does) or, when it
+// configures none, Ansible's default locations.
+func callbackPluginPath(runDir string, src *Source) string {
+	dirs := []string{runDir + "/callback_plugins"}
+	if src == nil || src.CallbackPlugins == "" {
+		return strings.Join(append(dirs,
+			"~/.ansible/plugins/callback",
+			"/usr/share/ansible/plugins/callback",
+		), ":")
+	}
+	for _, dir := range strings.Split(src.CallbackPlugins, ":") {
+		dir = strings.TrimSpace(dir)
+		switch {
+		case dir == "":
+			continue
+		case !path.IsAbs(dir) && !strings.HasPrefix(dir, "~") && !strings.HasPrefix(dir, "$"):
+			dir = path.Join(runDir, "project", src.WorkDir, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return strings.Join(dirs, ":")
+}
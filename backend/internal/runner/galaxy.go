@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GalaxyServer is an optional Galaxy or Automation Hub server configured on a
+// playbook source. When URL is empty ansible-galaxy uses its defaults.
+type GalaxyServer struct {
+	URL   string
+	Token string
+}
+
+// publicGalaxyURL is kept in the server list after a custom server so
+// dependencies that only exist on public Galaxy still resolve.
+const publicGalaxyURL = "https://galaxy.ansible.com/"
+
+// galaxyTokenEnv is the env var ansible-galaxy reads the custom server token from.
+const galaxyTokenEnv = "ANSIBLE_GALAXY_SERVER_SOURCE_TOKEN"
+
+// hasRequirements reports whether src needs a dependency install step.
+func hasRequirements(src *Source) bool {
+	return src.CollectionsRequirements != "" || src.RolesRequirements != ""
+}
+
+// galaxyInstallCmd returns the shell commands that install src's
+// requirements into depsDir, or "" when there is nothing to install.
+// projectDir is where the checkout was extracted. Output goes to the same
+// stream as ansible-playbook so it shows up in the run log.
+func galaxyInstallCmd(src *Source, projectDir, depsDir string) string {
+	var cmds []string
+	if src.CollectionsRequirements != "" {
+		cmds = append(cmds, fmt.Sprintf("ansible-galaxy collection install -r %s -p %s",
+			shellQuote(path.Join(projectDir, src.CollectionsRequirements)), shellQuote(path.Join(depsDir, "collections"))))
+	}
+	if src.RolesRequirements != "" {
+		cmds = append(cmds, fmt.Sprintf("ansible-galaxy role install -r %s -p %s",
+			shellQuote(path.Join(projectDir, src.RolesRequirements)), shellQuote(path.Join(depsDir, "roles"))))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// galaxyEnv returns the environment for the dependency step and the playbook
+// run: the per-run collections/roles paths are searched ahead of the
+// defaults, and a custom Galaxy server (if any) is put first in the server
+// list. The token is not included; callers supply it under galaxyTokenEnv
+// through a channel that keeps it off the command line.
+//
+// Setting ANSIBLE_COLLECTIONS_PATH / ANSIBLE_ROLES_PATH overrides the
+// equivalent ansible.cfg keys, so they are only set when something was
+// actually installed.
+func galaxyEnv(src *Source, server GalaxyServer, depsDir string) map[string]string {
+	env := map[string]string{}
+	if src.CollectionsRequirements != "" {
+		env["ANSIBLE_COLLECTIONS_PATH"] = path.Join(depsDir, "collections") + ":~/.ansible/collections:/usr/share/ansible/collections"
+	}
+	if src.RolesRequirements != "" {
+		env["ANSIBLE_ROLES_PATH"] = path.Join(depsDir, "roles") + ":~/.ansible/roles:/usr/share/ansible/roles:/etc/ansible/roles"
+	}
+	if server.URL != "" && hasRequirements(src) {
+		env["ANSIBLE_GALAXY_SERVER_LIST"] = "source,galaxy"
+		env["ANSIBLE_GALAXY_SERVER_SOURCE_URL"] = server.URL
+		env["ANSIBLE_GALAXY_SERVER_GALAXY_URL"] = publicGalaxyURL
+	}
+	return env
+}
+
+// envExports renders env as `export K='v'` lines in a stable order.
+func envExports(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(env[k]))
+	}
+	return b.String()
+}
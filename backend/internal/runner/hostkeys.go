@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeys is how a job runner's SSH host key is verified. A host
+// certificate signed by one of CAKeys is accepted; otherwise the key must
+// match Pinned. With neither configured the connection is refused unless
+// OnUnknown is set.
+type HostKeys struct {
+	// Pinned is the runner's host key in authorized_keys format
+	// ("ssh-ed25519 AAAA...").
+	Pinned string
+	// CAKeys holds host certificate authorities, one per line, either as a
+	// bare public key or as a known_hosts "@cert-authority <patterns> <key>"
+	// line restricting the CA to matching host names.
+	CAKeys string
+	// OnUnknown, when set, accepts a runner with no pinned key (trust on
+	// first use) and receives its key in authorized_keys format.
+	OnUnknown func(hostKey string)
+}
+
+// HostKeyError is returned when a runner's host key is not trusted. Pinned
+// is empty when no key was pinned for the runner.
+type HostKeyError struct {
+	Host    string
+	Pinned  string
+	Offered string
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Pinned == "" {
+		return fmt.Sprintf("no trusted host key for %s (offered %s); test the connection to trust it or pin the key", e.Host, Fingerprint(e.Offered))
+	}
+	return fmt.Sprintf("host key mismatch for %s: pinned %s, offered %s; if the host was rebuilt, re-pin its key", e.Host, Fingerprint(e.Pinned), Fingerprint(e.Offered))
+}
+
+// hostCA is one parsed CAKeys entry. An empty patterns list matches any host.
+type hostCA struct {
+	key      ssh.PublicKey
+	patterns []string
+}
+
+// ParseHostKey parses a single public key in authorized_keys format.
+func ParseHostKey(s string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(s)))
+	if err != nil {
+		return nil, fmt.Errorf("host key: %w", err)
+	}
+	if _, ok := key.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf("host key: pin the runner's plain key, not a certificate")
+	}
+	return key, nil
+}
+
+// parseHostCAs parses CAKeys. Blank lines and # comments are skipped.
+func parseHostCAs(s string) ([]hostCA, error) {
+	var cas []hostCA
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "@") {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err != nil {
+				return nil, fmt.Errorf("host CA line %d: %w", i+1, err)
+			}
+			cas = append(cas, hostCA{key: key})
+			continue
+		}
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("host CA line %d: %w", i+1, err)
+		}
+		if marker != "cert-authority" {
+			return nil, fmt.Errorf("host CA line %d: only @cert-authority lines are supported", i+1)
+		}
+		for _, p := range hosts {
+			if strings.HasPrefix(p, "!") {
+				return nil, fmt.Errorf("host CA line %d: negated host patterns are not supported", i+1)
+			}
+		}
+		cas = append(cas, hostCA{key: key, patterns: hosts})
+	}
+	return cas, nil
+}
+
+// ValidateHostKeys checks a pinned key and host CA list before they are saved.
+func ValidateHostKeys(pinned, caKeys string) error {
+	if strings.TrimSpace(pinned) != "" {
+		if _, err := ParseHostKey(pinned); err != nil {
+			return err
+		}
+	}
+	_, err := parseHostCAs(caKeys)
+	return err
+}
+
+// Fingerprint returns the SHA256 fingerprint of a key in authorized_keys
+// format, or "" if it does not parse.
+func Fingerprint(hostKey string) string {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(hostKey)))
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(key)
+}
+
+func marshalHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// clientConfig returns the host key callback for h, and the host key
+// algorithms to offer so the runner presents the pinned key type (or a
+// certificate) rather than whichever key it prefers.
+func (h HostKeys) clientConfig() (ssh.HostKeyCallback, []string, error) {
+	var pinned ssh.PublicKey
+	if strings.TrimSpace(h.Pinned) != "" {
+		var err error
+		if pinned, err = ParseHostKey(h.Pinned); err != nil {
+			return nil, nil, err
+		}
+	}
+	cas, err := parseHostCAs(h.CAKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var algos []string
+	if pinned != nil {
+		if len(cas) > 0 {
+			algos = append(algos, certAlgos...)
+		}
+		algos = append(algos, keyAlgos(pinned.Type())...)
+	}
+
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return isHostAuthority(cas, auth, address)
+		},
+	}
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if cert, ok := key.(*ssh.Certificate); ok && len(cas) > 0 {
+			if err := checker.CheckHostKey(hostname, remote, cert); err != nil {
+				return fmt.Errorf("host certificate for %s rejected: %w", hostname, err)
+			}
+			return nil
+		}
+		if pinned != nil {
+			if bytes.Equal(key.Marshal(), pinned.Marshal()) {
+				return nil
+			}
+			return &HostKeyError{Host: hostname, Pinned: marshalHostKey(pinned), Offered: marshalHostKey(key)}
+		}
+		if h.OnUnknown != nil {
+			h.OnUnknown(marshalHostKey(key))
+			return nil
+		}
+		return &HostKeyError{Host: hostname, Offered: marshalHostKey(key)}
+	}
+	return callback, algos, nil
+}
+
+// isHostAuthority reports whether auth is a CA trusted for address.
+// known_hosts patterns match the bare host name, or "[host]:port" for
+// non-standard ports.
+func isHostAuthority(cas []hostCA, auth ssh.PublicKey, address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	names := []string{host}
+	if port != "" && port != "22" {
+		names = append(names, "["+host+"]:"+port)
+	}
+	for _, ca := range cas {
+		if !bytes.Equal(ca.key.Marshal(), auth.Marshal()) {
+			continue
+		}
+		if len(ca.patterns) == 0 {
+			return true
+		}
+		for _, p := range ca.patterns {
+			for _, n := range names {
+				if ok, _ := path.Match(p, n); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+var certAlgos = []string{
+	ssh.CertAlgoED25519v01,
+	ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+	ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01,
+}
+
+// keyAlgos lists the host key algorithms that produce a key of keyType.
+func keyAlgos(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// InventoryListCommand returns the shell command that prints the
+// `ansible-inventory --list` JSON of inventory (a file, directory or
+// inventory plugin config). preCommand runs first in the same shell, as it
+// does for playbook runs.
+func InventoryListCommand(inventory, preCommand string) string {
+	cmd := "ansible-inventory -i " + shellQuote(inventory) + " --list"
+	if preCommand != "" {
+		return preCommand + " && " + cmd
+	}
+	return cmd
+}
+
+// LocalInventoryList runs InventoryListCommand on the server itself.
+func LocalInventoryList(ctx context.Context, inventory, preCommand string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "sh", "-c", InventoryListCommand(inventory, preCommand)).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("ansible-inventory: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("ansible-inventory: %w", err)
+	}
+	return out, nil
+}
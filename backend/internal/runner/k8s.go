@@ -1,17 +1,15 @@
 package runner
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -75,52 +73,59 @@ func k8sNamespace() string {
 	return "default"
 }
 
-// RunPlaybook creates a Kubernetes Job that runs ansible-playbook inside the
-// given container image, streams its output to outputCh, and returns the result.
-// All temporary resources (Job, ConfigMap, Secret) are cleaned up on return.
-func (r *K8sRunner) RunPlaybook(
-	ctx context.Context,
-	runID string,
-	image string,
-	playbookContent []byte,
-	inventoryTarget string,
-	variables map[string]interface{},
-	preCommand string,
-	vaultPassword string,
-	vaultFileContent []byte,
-	vaultFileName string,
-	sshCertContent []byte,
-	outputCh chan<- string,
-) RunResult {
-	// Resource names are derived from the first 8 chars of the run UUID.
-	prefix := "af-" + strings.ReplaceAll(runID, "-", "")[:8]
-	labels := map[string]string{"ansible-frontend/run-id": runID}
-
-	// If an SSH cert is provided, inject the key path into inventory before mounting.
-	// We copy the Secret-mounted file to /tmp/ansible-key and chmod 600 it in the
-	// shell command because Secret volumes are root-owned; a non-root UID can only
-	// read them if we set defaultMode 0644, but SSH rejects keys that aren't 0600
-	// owned by the current user. Copying to /tmp gives us a user-owned 0600 copy.
-	if len(sshCertContent) > 0 && inventoryTarget != "" {
-		inventoryTarget = strings.TrimSuffix(inventoryTarget, "\n") + " ansible_ssh_private_key_file=/tmp/ansible-key\n"
+// maxSourceArchiveSize keeps the packed playbook source under the 1 MiB
+// ConfigMap limit.
+const maxSourceArchiveSize = 900 * 1024
+
+// ExecutionEnvironmentRunner is a Runner that executes each run as a
+// Kubernetes Job using Image as the container (Execution Environment).
+type ExecutionEnvironmentRunner struct {
+	Image string
+}
+
+// NewExecutionEnvironmentRunner returns a Runner for the given EE image. The
+// Kubernetes client is initialised lazily on the first run.
+func NewExecutionEnvironmentRunner(image string) *ExecutionEnvironmentRunner {
+	return &ExecutionEnvironmentRunner{Image: image}
+}
+
+func (e *ExecutionEnvironmentRunner) Run(ctx context.Context, spec *RunSpec, outputCh chan<- string) RunResult {
+	k8s, err := GetK8sRunner()
+	if err != nil {
+		return RunResult{Err: fmt.Errorf("k8s runner unavailable: %w", err)}
 	}
+	return k8s.RunPlaybook(ctx, e.Image, spec, outputCh)
+}
 
-	// ── ConfigMap: playbook YAML (+ inventory + vault vars file if any) ────────
-	cmData := map[string]string{"playbook.yml": string(playbookContent)}
-	if inventoryTarget != "" {
-		cmData["inventory"] = inventoryTarget
+// RunPlaybook creates a Kubernetes Job that runs spec inside the given
+// container image, streams its output to outputCh, and returns the result.
+// The packed playbook source is shipped in the run's ConfigMap and the run
+// workspace (inventory, keys, vault material, env) in a Secret; both are
+// extracted into an emptyDir at /runner before ansible-playbook starts.
+// All temporary resources (Job, ConfigMap, Secret) are cleaned up on return.
+// When ctx is cancelled or times out, the Job's pod is deleted as well and
+// the log records whether it terminated.
+func (r *K8sRunner) RunPlaybook(ctx context.Context, image string, spec *RunSpec, outputCh chan<- string) RunResult {
+	src := spec.Source
+	if len(src.Archive) > maxSourceArchiveSize {
+		return RunResult{Err: fmt.Errorf("playbook source is %d KiB packed; Execution Environment runs are limited to %d KiB", len(src.Archive)/1024, maxSourceArchiveSize/1024)}
 	}
-	if len(vaultFileContent) > 0 {
-		cmData["vault-vars.yml"] = string(vaultFileContent)
-		// Also store under the original filename so the SubPath mount can place it
-		// at /ansible/<stem>/<filename> for vars_files: ./creds/creds.yml lookups.
-		if vaultFileName != "" {
-			cmData[vaultFileName] = string(vaultFileContent)
-		}
+
+	// Resource names are derived from the first 8 chars of the run UUID.
+	prefix := "af-" + strings.ReplaceAll(spec.RunID, "-", "")[:8]
+	labels := map[string]string{"ansible-frontend/run-id": spec.RunID}
+	sel := "ansible-frontend/run-id=" + spec.RunID
+
+	const runDir = "/runner"
+	workspace, err := buildWorkspace(spec, runDir)
+	if err != nil {
+		return RunResult{Err: err}
 	}
+
+	// ── ConfigMap: packed source ──────────────────────────────────────────────
 	cm, err := r.client.CoreV1().ConfigMaps(r.namespace).Create(ctx, &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{Name: prefix + "-cm", Namespace: r.namespace, Labels: labels},
-		Data:       cmData,
+		BinaryData: map[string][]byte{"source.tar.gz": src.Archive},
 	}, metav1.CreateOptions{})
 	if err != nil {
 		return RunResult{Err: fmt.Errorf("create configmap: %w", err)}
@@ -128,128 +133,68 @@ func (r *K8sRunner) RunPlaybook(
 	defer r.client.CoreV1().ConfigMaps(r.namespace).Delete(
 		context.Background(), cm.Name, metav1.DeleteOptions{})
 
-	// ── Secret: vault password (only when a vault is attached) ───────────────
-	var secretName string
-	if vaultPassword != "" {
-		secret, serr := r.client.CoreV1().Secrets(r.namespace).Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{Name: prefix + "-vault", Namespace: r.namespace, Labels: labels},
-			StringData: map[string]string{"password": vaultPassword},
-		}, metav1.CreateOptions{})
-		if serr != nil {
-			return RunResult{Err: fmt.Errorf("create vault secret: %w", serr)}
-		}
-		secretName = secret.Name
-		defer r.client.CoreV1().Secrets(r.namespace).Delete(
-			context.Background(), secretName, metav1.DeleteOptions{})
-	}
-
-	// ── Secret: SSH private key (only when a host cert is attached) ──────────
-	var certSecretName string
-	if len(sshCertContent) > 0 {
-		certSecret, cerr := r.client.CoreV1().Secrets(r.namespace).Create(ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{Name: prefix + "-cert", Namespace: r.namespace, Labels: labels},
-			Data:       map[string][]byte{"key": sshCertContent},
-		}, metav1.CreateOptions{})
-		if cerr != nil {
-			return RunResult{Err: fmt.Errorf("create cert secret: %w", cerr)}
-		}
-		certSecretName = certSecret.Name
-		defer r.client.CoreV1().Secrets(r.namespace).Delete(
-			context.Background(), certSecretName, metav1.DeleteOptions{})
+	// ── Secret: run workspace (inventory, SSH keys, vault material, env) ─────
+	secret, err := r.client.CoreV1().Secrets(r.namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: prefix + "-ws", Namespace: r.namespace, Labels: labels},
+		Data:       map[string][]byte{"workspace.tar.gz": workspace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return RunResult{Err: fmt.Errorf("create workspace secret: %w", err)}
 	}
+	defer r.client.CoreV1().Secrets(r.namespace).Delete(
+		context.Background(), secret.Name, metav1.DeleteOptions{})
 
 	// ── Build the shell command ───────────────────────────────────────────────
-	varJSON, err := json.Marshal(variables)
-	if err != nil {
-		return RunResult{Err: fmt.Errorf("marshal vars: %w", err)}
-	}
-	varStr := strings.ReplaceAll(string(varJSON), "'", `'"'"'`)
-	ansibleCmd := fmt.Sprintf("ansible-playbook /ansible/playbook.yml --extra-vars '%s'", varStr)
-	if inventoryTarget != "" {
-		ansibleCmd = fmt.Sprintf("ansible-playbook /ansible/playbook.yml -i /ansible/inventory --extra-vars '%s'", varStr)
-	}
-	if vaultPassword != "" {
-		ansibleCmd += " --vault-password-file /ansible-vault/password"
-	}
-	if len(vaultFileContent) > 0 {
-		ansibleCmd += " --extra-vars '@/ansible/vault-vars.yml'"
-	}
 	// EE containers often run as a non-root UID that has no /etc/passwd entry.
 	// SSH requires the current UID to resolve to a username; if it can't, it
 	// aborts with "No user exists for uid <N>". Prepend the standard OpenShift
 	// arbitrary-UID fix: write a passwd entry only when one is missing.
 	passwdFix := `if ! whoami &>/dev/null && [ -w /etc/passwd ]; then echo "user:x:$(id -u):$(id -g)::/tmp:/bin/sh" >> /etc/passwd; fi`
-	preamble := passwdFix
-	if len(sshCertContent) > 0 {
-		preamble += " && cp /ansible-cert/key /tmp/ansible-key && chmod 600 /tmp/ansible-key"
-	}
+	// Extract with Python rather than tar: every EE image ships Python for
+	// Ansible itself, while minimal base images do not always include tar.
+	// Extracting the workspace (rather than using the Secret mount directly)
+	// gives the SSH keys a 0600 mode owned by the container user, which SSH
+	// insists on and a Secret volume cannot provide for arbitrary UIDs.
+	extract := `python3 -c "import tarfile; tarfile.open('/ansible/source.tar.gz').extractall('/runner/project'); tarfile.open('/ansible-workspace/workspace.tar.gz').extractall('/runner')"`
+	shellCmd := passwdFix + " && " + extract + " && " + buildCommand(spec, runDir)
 
-	shellCmd := preamble + " && " + ansibleCmd
-	if preCommand != "" {
-		shellCmd = preamble + " && " + preCommand + " && " + ansibleCmd
+	env := []corev1.EnvVar{
+		{Name: "ANSIBLE_FORCE_COLOR", Value: "1"},
+		{Name: "HOME", Value: "/tmp"},
+		{Name: "ANSIBLE_HOST_KEY_CHECKING", Value: "False"},
 	}
 
 	// ── Volumes & mounts ─────────────────────────────────────────────────────
-	volumes := []corev1.Volume{{
-		Name: "playbook",
-		VolumeSource: corev1.VolumeSource{
-			ConfigMap: &corev1.ConfigMapVolumeSource{
-				LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
-			},
-		},
-	}}
-	// Use individual SubPath mounts for each ConfigMap key so we can also place
-	// the vault file at /ansible/<stem>/<filename> without overlapping mounts.
-	mounts := []corev1.VolumeMount{
-		{Name: "playbook", MountPath: "/ansible/playbook.yml", SubPath: "playbook.yml"},
-	}
-	if inventoryTarget != "" {
-		mounts = append(mounts, corev1.VolumeMount{
-			Name: "playbook", MountPath: "/ansible/inventory", SubPath: "inventory",
-		})
-	}
-	if len(vaultFileContent) > 0 {
-		mounts = append(mounts, corev1.VolumeMount{
-			Name: "playbook", MountPath: "/ansible/vault-vars.yml", SubPath: "vault-vars.yml",
-		})
-		if vaultFileName != "" {
-			stem := strings.TrimSuffix(vaultFileName, filepath.Ext(vaultFileName))
-			if stem != "" && stem != vaultFileName {
-				mounts = append(mounts, corev1.VolumeMount{
-					Name:      "playbook",
-					MountPath: fmt.Sprintf("/ansible/%s/%s", stem, vaultFileName),
-					SubPath:   vaultFileName,
-				})
-			}
-		}
-	}
-
-	if secretName != "" {
-		volumes = append(volumes, corev1.Volume{
-			Name: "vault",
+	// The ConfigMap and Secret are mounted read-only; everything is extracted
+	// into a writable emptyDir at /runner so playbooks can create files in it.
+	var mode int32 = 0644 // readable by non-root; the archive carries the real modes
+	volumes := []corev1.Volume{
+		{
+			Name: "playbook",
 			VolumeSource: corev1.VolumeSource{
-				Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+				},
 			},
-		})
-		mounts = append(mounts, corev1.VolumeMount{
-			Name: "vault", MountPath: "/ansible-vault", ReadOnly: true,
-		})
-	}
-
-	if certSecretName != "" {
-		var mode int32 = 0644 // readable by non-root; shell cmd copies to /tmp and chmod 600
-		volumes = append(volumes, corev1.Volume{
-			Name: "cert",
+		},
+		{
+			Name: "workspace",
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName:  certSecretName,
+					SecretName:  secret.Name,
 					DefaultMode: &mode,
 				},
 			},
-		})
-		mounts = append(mounts, corev1.VolumeMount{
-			Name: "cert", MountPath: "/ansible-cert", ReadOnly: true,
-		})
+		},
+		{
+			Name:         "work",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: "playbook", MountPath: "/ansible", ReadOnly: true},
+		{Name: "workspace", MountPath: "/ansible-workspace", ReadOnly: true},
+		{Name: "work", MountPath: runDir},
 	}
 
 	// ── Create Job ───────────────────────────────────────────────────────────
@@ -268,11 +213,7 @@ func (r *K8sRunner) RunPlaybook(
 						Name:         "ansible",
 						Image:        image,
 						Command:      []string{"sh", "-c", shellCmd},
-						Env: []corev1.EnvVar{
-							{Name: "ANSIBLE_FORCE_COLOR", Value: "1"},
-							{Name: "HOME", Value: "/tmp"},
-							{Name: "ANSIBLE_HOST_KEY_CHECKING", Value: "False"},
-						},
+						Env:          env,
 						VolumeMounts: mounts,
 					}},
 					Volumes: volumes,
@@ -284,22 +225,82 @@ func (r *K8sRunner) RunPlaybook(
 		return RunResult{Err: fmt.Errorf("create job: %w", err)}
 	}
 	defer func() {
+		if ctx.Err() != nil {
+			r.stopJob(job.Name, sel, outputCh)
+			return
+		}
 		prop := metav1.DeletePropagationForeground
 		r.client.BatchV1().Jobs(r.namespace).Delete(
 			context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &prop})
 	}()
 
 	// ── Wait for pod to become running or terminal ────────────────────────────
-	podName, err := r.waitForPod(ctx, runID, outputCh)
+	podName, err := r.waitForPod(ctx, spec.RunID, outputCh)
 	if err != nil {
 		return RunResult{Err: err}
 	}
 
 	// ── Stream logs ───────────────────────────────────────────────────────────
 	exitCode := r.streamAndWait(ctx, podName, outputCh)
+	if err := ctx.Err(); err != nil {
+		// The log stream ends with ctx, before the container has exited.
+		return RunResult{ExitCode: -1, Err: err}
+	}
 	return RunResult{ExitCode: exitCode}
 }
 
+// stopGracePeriod is how long a cancelled run's pod gets to exit after
+// SIGTERM; stopTimeout is how long stopJob waits for it to disappear.
+const (
+	stopGracePeriod = 10
+	stopTimeout     = 60 * time.Second
+)
+
+// stopJob deletes a cancelled or timed-out run's Job and its pod, then waits
+// for the pod to go away, reporting each step to outputCh.
+func (r *K8sRunner) stopJob(jobName, sel string, outputCh chan<- string) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+
+	grace := int64(stopGracePeriod)
+	prop := metav1.DeletePropagationBackground
+	err := r.client.BatchV1().Jobs(r.namespace).Delete(ctx, jobName, metav1.DeleteOptions{PropagationPolicy: &prop, GracePeriodSeconds: &grace})
+	switch {
+	case err == nil:
+		outputCh <- fmt.Sprintf("Run stopped: deleted Kubernetes Job %s.", jobName)
+	case apierrors.IsNotFound(err):
+		outputCh <- fmt.Sprintf("Run stopped: Kubernetes Job %s was already gone.", jobName)
+	default:
+		outputCh <- fmt.Sprintf("Run stopped: could not delete Kubernetes Job %s: %v", jobName, err)
+	}
+
+	// Background propagation leaves the pod to the garbage collector; delete
+	// it directly so the container is signalled now.
+	pods := r.client.CoreV1().Pods(r.namespace)
+	if err := pods.DeleteCollection(ctx, metav1.DeleteOptions{GracePeriodSeconds: &grace}, metav1.ListOptions{LabelSelector: sel}); err != nil && !apierrors.IsNotFound(err) {
+		outputCh <- fmt.Sprintf("Could not delete the run's pod: %v", err)
+	}
+	var remaining []string
+	for {
+		if list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: sel}); err == nil {
+			if len(list.Items) == 0 {
+				outputCh <- "Kubernetes pod terminated."
+				return
+			}
+			remaining = remaining[:0]
+			for _, p := range list.Items {
+				remaining = append(remaining, p.Name)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			outputCh <- fmt.Sprintf("Kubernetes pod %s still terminating after %s; the cluster will remove it.", strings.Join(remaining, ", "), stopTimeout)
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 // waitForPod polls until the Job's pod is Running, Succeeded, or Failed.
 // It reports image-pull errors immediately so the user sees them in the log.
 func (r *K8sRunner) waitForPod(ctx context.Context, runID string, outputCh chan<- string) (string, error) {
@@ -356,14 +357,15 @@ func (r *K8sRunner) streamAndWait(ctx context.Context, podName string, outputCh
 	}
 	defer stream.Close()
 
-	scanner := bufio.NewScanner(stream)
-	for scanner.Scan() {
-		line := scanner.Text()
-		select {
-		case outputCh <- line:
-		case <-ctx.Done():
-			return 1
+	if err := streamLines(ctx, stream, outputCh); err != nil {
+		// The pod may still be running; its exit code is unknown.
+		if ctx.Err() == nil {
+			select {
+			case outputCh <- fmt.Sprintf("error streaming logs: %v", err):
+			default:
+			}
 		}
+		return 1
 	}
 
 	// Retrieve exit code from the terminated container state.
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalRunner executes ansible-playbook as a subprocess of the server
+// itself, for installs without a separate job runner host or a cluster.
+// ansible-core must be installed in the server's image or host.
+type LocalRunner struct{}
+
+// NewLocalRunner returns a Runner that executes runs in-process.
+func NewLocalRunner() *LocalRunner {
+	return &LocalRunner{}
+}
+
+// Run extracts the source and workspace into a fresh temp directory and runs
+// ansible-playbook from there. The directory is removed afterwards.
+// Cancelling ctx terminates the whole process tree.
+func (r *LocalRunner) Run(ctx context.Context, spec *RunSpec, outputCh chan<- string) RunResult {
+	runDir, err := os.MkdirTemp("", "ansible-run-*")
+	if err != nil {
+		return RunResult{Err: fmt.Errorf("tempdir: %w", err)}
+	}
+	defer os.RemoveAll(runDir)
+	runDir = filepath.ToSlash(runDir)
+
+	workspace, err := buildWorkspace(spec, runDir)
+	if err != nil {
+		return RunResult{Err: err}
+	}
+	if err := extractArchive(spec.Source.Archive, filepath.Join(runDir, "project")); err != nil {
+		return RunResult{Err: fmt.Errorf("extract playbook source: %w", err)}
+	}
+	if err := extractArchive(workspace, runDir); err != nil {
+		return RunResult{Err: fmt.Errorf("extract run workspace: %w", err)}
+	}
+
+	cmd := exec.Command("sh", "-c", buildCommand(spec, runDir))
+	cmd.Dir = runDir
+	cmd.Env = append(os.Environ(),
+		"ANSIBLE_FORCE_COLOR=1",
+		"ANSIBLE_HOST_KEY_CHECKING=False",
+	)
+	setProcessGroup(cmd)
+
+	pr, pw := io.Pipe()
+	var buf bytes.Buffer
+	mw := io.MultiWriter(&buf, pw)
+	cmd.Stdout = mw
+	cmd.Stderr = mw
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pipeLines(ctx, pr, outputCh)
+	}()
+
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		<-done
+		return RunResult{Err: fmt.Errorf("start ansible-playbook: %w", err)}
+	}
+
+	runDone := make(chan error, 1)
+	go func() { runDone <- cmd.Wait() }()
+	var runErr error
+	select {
+	case runErr = <-runDone:
+	case <-ctx.Done():
+		signalProcessGroup(cmd, false)
+		select {
+		case <-runDone:
+		case <-time.After(10 * time.Second):
+			signalProcessGroup(cmd, true)
+			<-runDone
+		}
+		runErr = fmt.Errorf("cancelled")
+	}
+	pw.Close()
+	<-done // wait for scanner to finish
+
+	exitCode := 0
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			exitCode = exitErr.ExitCode()
+			runErr = nil // non-zero exit is a playbook failure, not a runner error
+		} else {
+			return RunResult{Err: runErr, Output: buf.String()}
+		}
+	}
+
+	return RunResult{
+		Output:   buf.String(),
+		ExitCode: exitCode,
+	}
+}
+
+// LocalVersion reports the first line of `ansible-playbook --version` on the
+// server, confirming the local runner can execute playbooks.
+func LocalVersion(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "ansible-playbook", "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ansible-playbook --version: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package runner
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalProcessGroup(cmd *exec.Cmd, kill bool) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+// Limits on user-supplied run options. Every value is shell-quoted when the
+// command line is built; validation is about keeping the values to what
+// ansible-playbook expects so a typo fails at submit time, not mid-run.
+const (
+	maxForks          = 500
+	maxVerbosity      = 4
+	maxOptionLength   = 1024
+	maxStartAtTaskLen = 256
+)
+
+var (
+	// Host patterns: names, groups, wildcards, ranges, regexes (~), and the
+	// :, :&, :! and , combinators. "@file" limits are rejected since they
+	// would read a file on the runner.
+	limitRe = regexp.MustCompile(`^[A-Za-z0-9_.:,!&*?\[\]~^$()|+\\/ -]+$`)
+	// Comma-separated tag names.
+	tagsRe = regexp.MustCompile(`^[A-Za-z0-9_.:-]+(\s*,\s*[A-Za-z0-9_.:-]+)*$`)
+)
+
+// ValidateOptions reports the first invalid field in o.
+func ValidateOptions(o models.RunOptions) error {
+	if o.Limit != "" {
+		if len(o.Limit) > maxOptionLength || !limitRe.MatchString(o.Limit) || strings.HasPrefix(o.Limit, "@") {
+			return fmt.Errorf("limit: %q is not a valid host pattern", o.Limit)
+		}
+	}
+	if o.Tags != "" && (len(o.Tags) > maxOptionLength || !tagsRe.MatchString(o.Tags)) {
+		return fmt.Errorf("tags: %q must be a comma-separated list of tag names", o.Tags)
+	}
+	if o.SkipTags != "" && (len(o.SkipTags) > maxOptionLength || !tagsRe.MatchString(o.SkipTags)) {
+		return fmt.Errorf("skip_tags: %q must be a comma-separated list of tag names", o.SkipTags)
+	}
+	if len(o.StartAtTask) > maxStartAtTaskLen {
+		return fmt.Errorf("start_at_task: must be at most %d characters", maxStartAtTaskLen)
+	}
+	if strings.ContainsFunc(o.StartAtTask, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("start_at_task: must not contain control characters")
+	}
+	if o.Forks < 0 || o.Forks > maxForks {
+		return fmt.Errorf("forks: must be between 1 and %d, or 0 for the default", maxForks)
+	}
+	if o.Verbosity < 0 || o.Verbosity > maxVerbosity {
+		return fmt.Errorf("verbosity: must be between 0 and %d", maxVerbosity)
+	}
+	return nil
+}
+
+// optionArgs returns the shell-quoted ansible-playbook flags for o. Values
+// use the --flag=value form so one starting with "-" can't be read as
+// another flag.
+func optionArgs(o models.RunOptions) []string {
+	var args []string
+	if o.Check {
+		args = append(args, "--check")
+	}
+	if o.Diff {
+		args = append(args, "--diff")
+	}
+	if o.Limit != "" {
+		args = append(args, shellQuote("--limit="+o.Limit))
+	}
+	if o.Tags != "" {
+		args = append(args, shellQuote("--tags="+tagList(o.Tags)))
+	}
+	if o.SkipTags != "" {
+		args = append(args, shellQuote("--skip-tags="+tagList(o.SkipTags)))
+	}
+	if o.StartAtTask != "" {
+		args = append(args, shellQuote("--start-at-task="+o.StartAtTask))
+	}
+	if o.Forks > 0 {
+		args = append(args, "--forks="+strconv.Itoa(o.Forks))
+	}
+	if o.Verbosity > 0 {
+		args = append(args, "-"+strings.Repeat("v", o.Verbosity))
+	}
+	return args
+}
+
+// tagList drops the whitespace ValidateOptions allows around commas, which
+// ansible-playbook would otherwise treat as part of the tag name.
+func tagList(s string) string {
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ",")
+}
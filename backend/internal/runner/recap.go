@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+var (
+	ansiRe     = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	recapRe    = regexp.MustCompile(`^PLAY RECAP \**$`)
+	recapRowRe = regexp.MustCompile(`^(\S+)\s+:\s+(\w+=\d+(?:\s+\w+=\d+)*)$`)
+)
+
+// ParseRecap returns the per-host counts from the last PLAY RECAP in a
+// run's output, or nil if there is none (the run failed before Ansible
+// finished, or the output was from a non-default stdout callback).
+func ParseRecap(output string) []models.RunHostStats {
+	lines := strings.Split(ansiRe.ReplaceAllString(output, ""), "\n")
+	start := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if recapRe.MatchString(strings.TrimSpace(lines[i])) {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	var stats []models.RunHostStats
+	for _, line := range lines[start:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(stats) > 0 {
+				break
+			}
+			continue
+		}
+		m := recapRowRe.FindStringSubmatch(line)
+		if m == nil {
+			break
+		}
+		s := models.RunHostStats{Host: m[1]}
+		for _, kv := range strings.Fields(m[2]) {
+			k, v, _ := strings.Cut(kv, "=")
+			n, _ := strconv.Atoi(v)
+			switch k {
+			case "ok":
+				s.OK = n
+			case "changed":
+				s.Changed = n
+			case "unreachable":
+				s.Unreachable = n
+			case "failed":
+				s.Failed = n
+			case "skipped":
+				s.Skipped = n
+			case "rescued":
+				s.Rescued = n
+			case "ignored":
+				s.Ignored = n
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+// Runner backends selectable per job runner (models.Server.RunnerType).
+const (
+	KindSSH        = "ssh"
+	KindKubernetes = "kubernetes"
+	KindLocal      = "local"
+)
+
+// Runner executes a playbook run described by a RunSpec. Implementations
+// stream output lines to outputCh as they arrive; the caller closes outputCh
+// after Run returns. A non-zero ExitCode is a playbook failure, Err is a
+// runner failure (connection, scheduling, cancellation…).
+type Runner interface {
+	Run(ctx context.Context, spec *RunSpec, outputCh chan<- string) RunResult
+}
+
+// RunSpec is everything a runner needs to execute one ansible-playbook run.
+type RunSpec struct {
+	RunID  string
+	Source *Source // packed playbook repository (see PackSource)
+
+	// Inventory is the YAML (or, for older runs, INI) inventory content; empty
+	// uses the runner's default inventory. HostCerts maps inventory host names
+	// to SSH private keys, which are wired up through host_vars as
+	// ansible_ssh_private_key_file.
+	Inventory string
+	HostCerts map[string][]byte
+
+	Variables map[string]interface{} // passed as --extra-vars
+
+	// Vault material: the password is passed via --vault-password-file, the
+	// encrypted vars file via --extra-vars @file.
+	VaultPassword string
+	VaultFile     []byte
+	VaultFileName string
+
+	Galaxy GalaxyServer // server for requirements.yml installs
+
+	// PreCommand runs in the same shell before ansible-playbook (e.g. to
+	// activate a virtualenv). Env is exported before PreCommand runs.
+	PreCommand string
+	Env        map[string]string
+
+	Options models.RunOptions // validated with ValidateOptions
+
+	// AdHoc, when set, runs a single module with ansible instead of
+	// ansible-playbook; Source is then AdHocSource().
+	AdHoc *AdHoc
+}
+
+// RunResult is the outcome of a Runner.Run call.
+type RunResult struct {
+	Output   string
+	ExitCode int
+	Err      error
+}
+
+// maxOutputLine bounds one line of run output. Event lines carrying a host's
+// facts are far longer than bufio.Scanner's default 64 KiB token.
+const maxOutputLine = 8 << 20
+
+// streamLines sends each line read from r to outputCh until r ends or ctx is
+// done. A line longer than maxOutputLine ends the stream with
+// bufio.ErrTooLong.
+func streamLines(ctx context.Context, r io.Reader, outputCh chan<- string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxOutputLine)
+	for scanner.Scan() {
+		select {
+		case outputCh <- scanner.Text():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// pipeLines streams the lines written to pr to outputCh, then drains pr so
+// the command writing to it never blocks, even when streaming stopped early.
+func pipeLines(ctx context.Context, pr *io.PipeReader, outputCh chan<- string) {
+	if err := streamLines(ctx, pr, outputCh); err != nil && ctx.Err() == nil {
+		select {
+		case outputCh <- "error reading output: " + err.Error():
+		case <-ctx.Done():
+		}
+	}
+	_, _ = io.Copy(io.Discard, pr)
+}
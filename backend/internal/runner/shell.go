@@ -0,0 +1,9 @@
+package runner
+
+import "strings"
+
+// shellQuote wraps s in single quotes for POSIX shells, escaping any
+// embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
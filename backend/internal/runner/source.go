@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a packed checkout of a playbook repository, ready to be shipped
+// to a runner and extracted into a per-run working directory.
+type Source struct {
+	Archive  []byte // gzipped tar of the checkout (without .git)
+	WorkDir  string // directory, relative to the checkout root, ansible-playbook runs from
+	Playbook string // playbook path relative to WorkDir
+
+	// Galaxy requirements files found in the checkout, relative to its root.
+	// Empty when the repository has none.
+	CollectionsRequirements string
+	RolesRequirements       string
+
+	// CallbackPlugins is the callback_plugins setting of the ansible.cfg in
+	// WorkDir, "" when it has none.
+	CallbackPlugins string
+}
+
+// PackSource archives the checkout at root and works out where the playbook
+// should be run from. ansible-playbook only reads ansible.cfg from the current
+// directory, so the working directory is the closest ancestor of the playbook
+// that holds an ansible.cfg, or the checkout root when there is none. roles/,
+// group_vars/, templates/ etc. are resolved relative to the playbook itself
+// and work either way.
+func PackSource(root, playbookPath string) (*Source, error) {
+	playbookPath = path.Clean(filepath.ToSlash(playbookPath))
+	if playbookPath == "." || strings.HasPrefix(playbookPath, "../") || path.IsAbs(playbookPath) {
+		return nil, fmt.Errorf("invalid playbook path %q", playbookPath)
+	}
+	if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(playbookPath))); err != nil {
+		return nil, fmt.Errorf("read %s: %w", playbookPath, err)
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", playbookPath)
+	}
+
+	workDir := "."
+	for dir := path.Dir(playbookPath); dir != "."; dir = path.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir), "ansible.cfg")); err == nil {
+			workDir = dir
+			break
+		}
+	}
+	playbook := playbookPath
+	if workDir != "." {
+		playbook = strings.TrimPrefix(playbookPath, workDir+"/")
+	}
+
+	archive, err := packDir(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{
+		Archive:                 archive,
+		WorkDir:                 workDir,
+		Playbook:                playbook,
+		CollectionsRequirements: findRequirements(root, workDir, "collections"),
+		RolesRequirements:       findRequirements(root, workDir, "roles"),
+		CallbackPlugins:         configuredCallbackPlugins(root, workDir),
+	}, nil
+}
+
+// configuredCallbackPlugins reads callback_plugins from the [defaults]
+// section of the ansible.cfg in workDir.
+func configuredCallbackPlugins(root, workDir string) string {
+	cfg, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(workDir), "ansible.cfg"))
+	if err != nil {
+		return ""
+	}
+	section := ""
+	for _, line := range strings.Split(string(cfg), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if section == "defaults" && i > 0 && strings.TrimSpace(line[:i]) == "callback_plugins" {
+			return strings.TrimSpace(line[i+1:])
+		}
+	}
+	return ""
+}
+
+// findRequirements looks for <kind>/requirements.yml (or .yaml) in the
+// playbook's working directory first, then in the checkout root.
+func findRequirements(root, workDir, kind string) string {
+	dirs := []string{workDir}
+	if workDir != "." {
+		dirs = append(dirs, ".")
+	}
+	for _, dir := range dirs {
+		for _, name := range []string{"requirements.yml", "requirements.yaml"} {
+			rel := path.Join(dir, kind, name)
+			if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err == nil && info.Mode().IsRegular() {
+				return rel
+			}
+		}
+	}
+	return ""
+}
+
+// packDir writes every regular file, directory and symlink under root into a
+// gzipped tar. The .git directory is skipped — runs never need history.
+func packDir(root string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil // sockets, devices etc. have no place in a playbook repo
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pack source: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("pack source: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("pack source: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -3,6 +3,8 @@ package runner
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,32 +13,129 @@ import (
 
 type SSHClient struct {
 	client *ssh.Client
+	// chain holds the connections to any jump hosts, outermost first.
+	chain []*ssh.Client
 }
 
-func Connect(host string, port int, username, privateKeyPEM string) (*SSHClient, error) {
-	pem := strings.TrimSpace(privateKeyPEM)
+// Hop is one SSH connection on the way to a job runner: a jump host, or the
+// runner itself as the last hop of a chain.
+type Hop struct {
+	Name          string // shown in errors
+	Host          string
+	Port          int
+	Username      string
+	SSHPrivateKey string
+	HostKeys      HostKeys
+}
+
+func (h Hop) addr() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+// HopError reports which hop of a connection chain failed.
+type HopError struct {
+	Index int // 0-based position in the chain
+	Count int // total hops, including the runner
+	Name  string
+	Addr  string
+	Err   error
+}
+
+func (e *HopError) Error() string {
+	if e.Index == e.Count-1 {
+		return fmt.Sprintf("runner %q (%s): %v", e.Name, e.Addr, e.Err)
+	}
+	return fmt.Sprintf("jump host %d of %d %q (%s): %v", e.Index+1, e.Count-1, e.Name, e.Addr, e.Err)
+}
+
+func (e *HopError) Unwrap() error { return e.Err }
+
+// Connect opens an SSH connection to the last of hops, tunnelling through
+// each earlier hop in turn with ssh.Client.Dial. Every hop's host key is
+// verified against its own HostKeys.
+func Connect(hops ...Hop) (*SSHClient, error) {
+	if len(hops) == 0 {
+		return nil, fmt.Errorf("connect: no hosts given")
+	}
+	var chain []*ssh.Client
+	closeChain := func() {
+		for i := len(chain) - 1; i >= 0; i-- {
+			chain[i].Close()
+		}
+	}
+	for i, hop := range hops {
+		var prev *ssh.Client
+		if i > 0 {
+			prev = chain[i-1]
+		}
+		client, err := dialHop(prev, hop)
+		if err != nil {
+			closeChain()
+			return nil, &HopError{Index: i, Count: len(hops), Name: hop.Name, Addr: hop.addr(), Err: err}
+		}
+		chain = append(chain, client)
+	}
+	return &SSHClient{client: chain[len(chain)-1], chain: chain[:len(chain)-1]}, nil
+}
+
+// dialHop connects to hop directly, or through prev when it is set.
+func dialHop(prev *ssh.Client, hop Hop) (*ssh.Client, error) {
+	pem := strings.TrimSpace(hop.SSHPrivateKey)
 	signer, err := ssh.ParsePrivateKey([]byte(pem))
 	if err != nil {
 		return nil, fmt.Errorf("parse private key: %w", err)
 	}
 
+	hostKeyCallback, hostKeyAlgos, err := hop.HostKeys.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	config := &ssh.ClientConfig{
-		User:            username,
-		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
-		Timeout:         30 * time.Second,
+		User:              hop.Username,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgos,
+		Timeout:           30 * time.Second,
+	}
+
+	addr := hop.addr()
+	if prev == nil {
+		client, err := ssh.Dial("tcp", addr, config)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %w", err)
+		}
+		return client, nil
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, config)
+	conn, err := prev.Dial("tcp", addr)
 	if err != nil {
-		return nil, fmt.Errorf("dial %s: %w", addr, err)
+		return nil, fmt.Errorf("dial through previous hop: %w", err)
 	}
-	return &SSHClient{client: client}, nil
+	// Tunnelled connections don't support deadlines, so bound the handshake
+	// by closing the channel if it takes longer than the dial timeout.
+	timer := time.AfterFunc(config.Timeout, func() { conn.Close() })
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() {
+		if err == nil {
+			ncc.Close()
+		}
+		return nil, fmt.Errorf("handshake timed out after %s", config.Timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(ncc, chans, reqs), nil
 }
 
+// Close closes the connection to the runner and then any jump hosts.
 func (c *SSHClient) Close() error {
-	return c.client.Close()
+	err := c.client.Close()
+	for i := len(c.chain) - 1; i >= 0; i-- {
+		c.chain[i].Close()
+	}
+	return err
 }
 
 // UploadFile writes content to remotePath on the remote host using cat via SSH stdin.
@@ -55,6 +154,23 @@ func (c *SSHClient) UploadFile(content []byte, remotePath string) error {
 	return nil
 }
 
+// ExtractArchive extracts a gzipped tar (a packed source or run workspace)
+// into dir on the remote host, creating dir if needed.
+func (c *SSHClient) ExtractArchive(archive []byte, dir string) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("new session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = bytes.NewReader(archive)
+	cmd := fmt.Sprintf("mkdir -p %s && tar -xzf - -C %s", shellQuote(dir), shellQuote(dir))
+	if out, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("extract to %s: %w: %s", dir, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // RunCommand executes a simple command and returns combined output.
 func (c *SSHClient) RunCommand(cmd string) (string, error) {
 	session, err := c.client.NewSession()
@@ -66,3 +182,21 @@ func (c *SSHClient) RunCommand(cmd string) (string, error) {
 	out, err := session.CombinedOutput(cmd)
 	return string(out), err
 }
+
+// Output executes a command and returns its standard output. Standard error
+// is included in the returned error when the command fails.
+func (c *SSHClient) Output(cmd string) ([]byte, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(cmd); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Every backend lays a run out the same way under its run directory:
+//
+//	project/                 the extracted playbook source
+//	inventory/hosts          spec.Inventory
+//	inventory/host_vars/     ansible_ssh_private_key_file per host with a cert
+//	keys/                    SSH private keys (0600)
+//	extra-vars.json          spec.Variables
+//	vault-pass, vault-vars.yml
+//	env                      exports sourced before anything else runs (0600)
+//	deps/                    Galaxy collections and roles installed for this run
+//	callback_plugins/        the events callback plugin (see events.go)
+//
+// The support files are shipped as a second archive next to the source so
+// the SSH, Kubernetes and local backends share one layout and one command.
+
+// archiveFile is a single in-memory file destined for a tar archive.
+type archiveFile struct {
+	name string
+	mode int64
+	data []byte
+}
+
+// buildWorkspace renders the support files for spec as a gzipped tar whose
+// paths are relative to runDir.
+func buildWorkspace(spec *RunSpec, runDir string) ([]byte, error) {
+	varJSON, err := json.Marshal(spec.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("marshal vars: %w", err)
+	}
+	files := []archiveFile{{name: "extra-vars.json", mode: 0600, data: varJSON}}
+
+	env := map[string]string{"ANSIBLE_CALLBACK_PLUGINS": callbackPluginPath(runDir, spec.Source)}
+	if spec.AdHoc != nil {
+		// ansible only loads callback plugins other than the stdout one when asked.
+		env["ANSIBLE_LOAD_CALLBACK_PLUGINS"] = "True"
+	}
+	for k, v := range spec.Env {
+		env[k] = v
+	}
+	if hasRequirements(spec.Source) {
+		for k, v := range galaxyEnv(spec.Source, spec.Galaxy, path.Join(runDir, "deps")) {
+			env[k] = v
+		}
+		if spec.Galaxy.URL != "" && spec.Galaxy.Token != "" {
+			env[galaxyTokenEnv] = spec.Galaxy.Token
+		}
+	}
+	files = append(files,
+		archiveFile{name: "env", mode: 0600, data: []byte(envExports(env))},
+		archiveFile{name: "callback_plugins/" + eventsCallbackName, mode: 0644, data: eventsCallback},
+	)
+
+	if spec.Inventory != "" {
+		files = append(files, archiveFile{name: "inventory/hosts", mode: 0644, data: []byte(spec.Inventory)})
+		hosts := make([]string, 0, len(spec.HostCerts))
+		for h := range spec.HostCerts {
+			hosts = append(hosts, h)
+		}
+		sort.Strings(hosts)
+		for i, h := range hosts {
+			if len(spec.HostCerts[h]) == 0 || strings.ContainsAny(h, `/\`) {
+				continue
+			}
+			keyPath := path.Join(runDir, "keys", fmt.Sprintf("key-%d", i))
+			files = append(files,
+				archiveFile{name: path.Join("keys", path.Base(keyPath)), mode: 0600, data: spec.HostCerts[h]},
+				archiveFile{name: path.Join("inventory", "host_vars", h+".yml"), mode: 0644,
+					data: []byte("ansible_ssh_private_key_file: " + strings.TrimSpace(mustJSON(keyPath)) + "\n")},
+			)
+		}
+	}
+
+	if spec.VaultPassword != "" {
+		files = append(files, archiveFile{name: "vault-pass", mode: 0600, data: []byte(spec.VaultPassword)})
+	}
+	if len(spec.VaultFile) > 0 {
+		files = append(files, archiveFile{name: "vault-vars.yml", mode: 0600, data: spec.VaultFile})
+	}
+	return packFiles(files)
+}
+
+// buildCommand returns the shell command that runs spec from runDir once
+// the source and workspace archives have been extracted there.
+func buildCommand(spec *RunSpec, runDir string) string {
+	src := spec.Source
+	projectDir := path.Join(runDir, "project")
+	workDir := path.Join(projectDir, src.WorkDir)
+	if spec.AdHoc != nil {
+		// The empty ad-hoc source has no checkout to run from.
+		workDir = runDir
+	}
+
+	steps := []string{". " + shellQuote(path.Join(runDir, "env"))}
+	if spec.PreCommand != "" {
+		// Run pre-command in the same shell so its environment changes
+		// (e.g. PATH from virtualenv activate) are inherited by ansible-playbook.
+		steps = append(steps, spec.PreCommand)
+	}
+	steps = append(steps, "cd "+shellQuote(workDir))
+
+	// Also place the vault file at <playbook dir>/<stem>/<filename> so
+	// playbooks using the vars_files: ./creds/creds.yml (stem-as-dir)
+	// convention find it.
+	if len(spec.VaultFile) > 0 && spec.VaultFileName != "" {
+		stem := strings.TrimSuffix(spec.VaultFileName, filepath.Ext(spec.VaultFileName))
+		if stem != "" && stem != spec.VaultFileName && !strings.ContainsAny(spec.VaultFileName, `/\`) {
+			stemDir := path.Join(workDir, path.Dir(src.Playbook), stem)
+			steps = append(steps, fmt.Sprintf("mkdir -p %s && cp %s %s",
+				shellQuote(stemDir), shellQuote(path.Join(runDir, "vault-vars.yml")), shellQuote(path.Join(stemDir, spec.VaultFileName))))
+		}
+	}
+
+	if hasRequirements(src) {
+		steps = append(steps, galaxyInstallCmd(src, projectDir, path.Join(runDir, "deps")))
+	}
+
+	args := []string{"ansible-playbook", shellQuote(src.Playbook)}
+	if spec.AdHoc != nil {
+		args = spec.AdHoc.args()
+	}
+	if spec.Inventory != "" {
+		args = append(args, "-i", shellQuote(path.Join(runDir, "inventory")))
+	}
+	args = append(args, "--extra-vars", shellQuote("@"+path.Join(runDir, "extra-vars.json")))
+	if spec.VaultPassword != "" {
+		args = append(args, "--vault-password-file", shellQuote(path.Join(runDir, "vault-pass")))
+	}
+	if len(spec.VaultFile) > 0 {
+		args = append(args, "--extra-vars", shellQuote("@"+path.Join(runDir, "vault-vars.yml")))
+	}
+	args = append(args, optionArgs(spec.Options)...)
+	steps = append(steps, strings.Join(args, " "))
+
+	return strings.Join(steps, " && ")
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// packFiles writes in-memory files into a gzipped tar, creating parent
+// directories as needed.
+func packFiles(files []archiveFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	dirs := map[string]bool{}
+	for _, f := range files {
+		for dir := path.Dir(f.name); dir != "."; dir = path.Dir(dir) {
+			if dirs[dir] {
+				break
+			}
+			dirs[dir] = true
+		}
+	}
+	dirList := make([]string, 0, len(dirs))
+	for d := range dirs {
+		dirList = append(dirList, d)
+	}
+	sort.Strings(dirList)
+	for _, d := range dirList {
+		mode := int64(0755)
+		if d == "keys" {
+			mode = 0700
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: d + "/", Mode: mode, ModTime: now}); err != nil {
+			return nil, fmt.Errorf("pack workspace: %w", err)
+		}
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: f.mode, Size: int64(len(f.data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("pack workspace: %w", err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, fmt.Errorf("pack workspace: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("pack workspace: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("pack workspace: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// extractArchive unpacks a gzipped tar produced by PackSource or packFiles
+// into dir on the local filesystem. Entries that would escape dir are rejected.
+func extractArchive(archive []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("extract: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if name == "." || name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("extract: invalid entry %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode).Perm()|0700); err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("extract: %w", err)
+			}
+		}
+	}
+}
@@ -12,8 +12,8 @@ import (
 // TriggerFunc is called on each cron tick with the form and its default variables.
 type TriggerFunc func(form *models.Form, variables map[string]interface{})
 
-// Scheduler wraps robfig/cron and maintains a registry of formID → cron entry
-// so schedules can be updated or removed when forms change.
+// Scheduler wraps robfig/cron and maintains a registry of formID (or job key)
+// → cron entry so schedules can be updated or removed when forms change.
 type Scheduler struct {
 	c       *cron.Cron
 	mu      sync.Mutex
@@ -74,21 +74,49 @@ func (s *Scheduler) Upsert(form *models.Form) {
 	log.Printf("[scheduler] registered form %q (%s) with schedule %q", form.Name, form.ID, form.ScheduleCron)
 }
 
-// Remove cancels any scheduled entry for the given form ID.
-func (s *Scheduler) Remove(formID string) {
+// UpsertFunc registers or replaces a schedule that calls fn, for jobs other
+// than forms. key must not collide with a form ID; callers prefix it with the
+// kind of job (e.g. "inventory-source:<id>"). An empty expr only removes the
+// existing entry.
+func (s *Scheduler) UpsertFunc(key, expr string, fn func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if id, ok := s.entries[formID]; ok {
+
+	if id, ok := s.entries[key]; ok {
+		s.c.Remove(id)
+		delete(s.entries, key)
+	}
+	if expr == "" {
+		return
+	}
+	eid, err := s.c.AddFunc(expr, func() {
+		log.Printf("[scheduler] triggering %s", key)
+		fn()
+	})
+	if err != nil {
+		log.Printf("[scheduler] failed to register cron %q for %s: %v", expr, key, err)
+		return
+	}
+	s.entries[key] = eid
+	log.Printf("[scheduler] registered %s with schedule %q", key, expr)
+}
+
+// Remove cancels any scheduled entry for the given form ID or UpsertFunc key.
+func (s *Scheduler) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.entries[key]; ok {
 		s.c.Remove(id)
-		delete(s.entries, formID)
+		delete(s.entries, key)
 	}
 }
 
-// NextRunAt returns the next scheduled time for a form, or nil if not scheduled.
-func (s *Scheduler) NextRunAt(formID string) *time.Time {
+// NextRunAt returns the next scheduled time for a form ID or UpsertFunc key,
+// or nil if not scheduled.
+func (s *Scheduler) NextRunAt(key string) *time.Time {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	id, ok := s.entries[formID]
+	id, ok := s.entries[key]
 	if !ok {
 		return nil
 	}
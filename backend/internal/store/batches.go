@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/google/uuid"
+)
+
+// BatchStore tracks server-group launches and the progress of their rollout.
+type BatchStore struct {
+	db *sql.DB
+}
+
+const batchSelect = `SELECT b.id, b.form_id, COALESCE(f.name, ''), b.rollout, b.status, b.phase, b.phases, b.message, b.next_phase_at, b.created_at, b.finished_at
+	FROM batches b LEFT JOIN forms f ON f.id = b.form_id`
+
+func scanBatch(row interface {
+	Scan(...any) error
+}) (*models.Batch, error) {
+	b := &models.Batch{}
+	var rollout string
+	if err := row.Scan(&b.ID, &b.FormID, &b.FormName, &rollout, &b.Status, &b.Phase, &b.Phases, &b.Message, &b.NextPhaseAt, &b.CreatedAt, &b.FinishedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(rollout), &b.Rollout)
+	return b, nil
+}
+
+// Create records a batch whose first rollout batch is starting.
+func (s *BatchStore) Create(formID *string, rollout models.Rollout, phases int) (*models.Batch, error) {
+	b := &models.Batch{
+		ID:        uuid.New().String(),
+		FormID:    formID,
+		Rollout:   rollout,
+		Status:    "running",
+		Phase:     1,
+		Phases:    phases,
+		CreatedAt: time.Now(),
+	}
+	rolloutJSON, _ := json.Marshal(rollout)
+	_, err := s.db.Exec(
+		"INSERT INTO batches (id, form_id, rollout, status, phase, phases, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		b.ID, b.FormID, string(rolloutJSON), b.Status, b.Phase, b.Phases, b.CreatedAt,
+	)
+	return b, err
+}
+
+func (s *BatchStore) Get(id string) (*models.Batch, error) {
+	b, err := scanBatch(s.db.QueryRow(batchSelect+" WHERE b.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// ListActive returns the IDs of batches whose rollout has not finished.
+func (s *BatchStore) ListActive() ([]string, error) {
+	rows, err := s.db.Query("SELECT id FROM batches WHERE finished_at IS NULL ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetState records the rollout's progress. nextPhaseAt is only set while
+// paused between batches.
+func (s *BatchStore) SetState(id, status string, phase int, message string, nextPhaseAt *time.Time) error {
+	_, err := s.db.Exec(
+		"UPDATE batches SET status = ?, phase = ?, message = ?, next_phase_at = ? WHERE id = ?",
+		status, phase, message, nextPhaseAt, id,
+	)
+	return err
+}
+
+// Finish ends the rollout with a final status.
+func (s *BatchStore) Finish(id, status, message string) error {
+	_, err := s.db.Exec(
+		"UPDATE batches SET status = ?, message = ?, next_phase_at = NULL, finished_at = ? WHERE id = ?",
+		status, message, time.Now(), id,
+	)
+	return err
+}
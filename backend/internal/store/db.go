@@ -94,7 +94,14 @@ func New(dsn string) (*DB, error) {
 	db.Exec(`ALTER TABLE forms ADD COLUMN host_id TEXT REFERENCES hosts(id) ON DELETE SET NULL`)
 	db.Exec("ALTER TABLE runs ADD COLUMN batch_id TEXT")
 	db.Exec("ALTER TABLE servers ADD COLUMN execution_environment TEXT NOT NULL DEFAULT ''")
-	db.Exec("ALTER TABLE hosts ADD COLUMN ssh_cert_id TEXT REFERENCES ssh_certs(id) ON DELETE SET NULL")
+	// Runner backend: existing servers were implicitly Kubernetes when they had
+	// an execution environment image and SSH otherwise.
+	db.Exec("ALTER TABLE servers ADD COLUMN runner_type TEXT NOT NULL DEFAULT ''")
+	db.Exec("UPDATE servers SET runner_type = CASE WHEN execution_environment != '' THEN 'kubernetes' ELSE 'ssh' END WHERE runner_type = ''")
+	// Job runner host key verification: a pinned key and/or trusted host CAs.
+	db.Exec("ALTER TABLE servers ADD COLUMN host_key TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE servers ADD COLUMN host_ca_keys TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE servers ADD COLUMN jump_server_id TEXT REFERENCES servers(id) ON DELETE SET NULL")
 
 	// Migrate playbooks: if the old file_path column exists (pre-git schema), drop and
 	// recreate. If the transitional playbook_path column exists, drop and recreate without
@@ -118,9 +125,59 @@ func New(dsn string) (*DB, error) {
 		db.Exec("DELETE FROM forms WHERE playbook_id NOT IN (SELECT id FROM playbooks)")
 		db.Exec("PRAGMA foreign_keys = ON")
 	}
+	db.Exec("ALTER TABLE playbooks ADD COLUMN galaxy_server_url TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE playbooks ADD COLUMN galaxy_token_enc TEXT NOT NULL DEFAULT ''")
 	// Add playbook_path to forms (the specific .yml file within the source repo).
 	db.Exec("ALTER TABLE forms ADD COLUMN playbook_path TEXT NOT NULL DEFAULT ''")
 	db.Exec("ALTER TABLE forms ADD COLUMN status TEXT NOT NULL DEFAULT 'draft'")
+	db.Exec("ALTER TABLE runs ADD COLUMN check_mode INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE runs ADD COLUMN diff_mode INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE forms ADD COLUMN schedule_check_mode INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE forms ADD COLUMN schedule_diff_mode INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE runs ADD COLUMN options TEXT NOT NULL DEFAULT '{}'")
+	db.Exec("ALTER TABLE forms ADD COLUMN run_options TEXT NOT NULL DEFAULT '{}'")
+	db.Exec("ALTER TABLE forms ADD COLUMN overridable_options TEXT NOT NULL DEFAULT '[]'")
+	db.Exec("ALTER TABLE servers ADD COLUMN max_concurrent_runs INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE forms ADD COLUMN max_concurrent_runs INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE forms ADD COLUMN max_runtime_minutes INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE runs ADD COLUMN cancelled_by TEXT")
+
+	// Rebuild runs so its status CHECK allows 'cancelled' and 'timed_out'.
+	// legacy_alter_table keeps the FKs in run_queue, run_plays etc. pointing
+	// at "runs", which then resolves to the rebuilt table.
+	var runsSchema string
+	db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='runs'").Scan(&runsSchema)
+	if !strings.Contains(runsSchema, "'timed_out'") {
+		db.Exec("PRAGMA legacy_alter_table = ON")
+		db.Exec("PRAGMA foreign_keys = OFF")
+		db.Exec("ALTER TABLE runs RENAME TO _runs_old")
+		db.Exec(`CREATE TABLE runs (
+			id           TEXT PRIMARY KEY,
+			form_id      TEXT REFERENCES forms(id) ON DELETE SET NULL,
+			playbook_id  TEXT NOT NULL REFERENCES playbooks(id),
+			server_id    TEXT NOT NULL REFERENCES servers(id),
+			variables    TEXT NOT NULL DEFAULT '{}',
+			status       TEXT NOT NULL CHECK(status IN ('pending','running','success','failed','cancelled','timed_out')) DEFAULT 'pending',
+			output       TEXT NOT NULL DEFAULT '',
+			batch_id     TEXT,
+			check_mode   INTEGER NOT NULL DEFAULT 0,
+			diff_mode    INTEGER NOT NULL DEFAULT 0,
+			options      TEXT NOT NULL DEFAULT '{}',
+			cancelled_by TEXT,
+			started_at   DATETIME,
+			finished_at  DATETIME
+		)`)
+		db.Exec(`INSERT INTO runs (id, form_id, playbook_id, server_id, variables, status, output, batch_id, check_mode, diff_mode, options, cancelled_by, started_at, finished_at)
+			SELECT id, form_id, playbook_id, server_id, variables, status, output, batch_id, check_mode, diff_mode, options, cancelled_by, started_at, finished_at FROM _runs_old`)
+		db.Exec("DROP TABLE _runs_old")
+		db.Exec("PRAGMA foreign_keys = ON")
+		db.Exec("PRAGMA legacy_alter_table = OFF")
+	}
+	db.Exec("ALTER TABLE runs ADD COLUMN inventory TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE runs ADD COLUMN parent_run_id TEXT REFERENCES runs(id) ON DELETE SET NULL")
+	db.Exec("ALTER TABLE forms ADD COLUMN rollout TEXT NOT NULL DEFAULT '{}'")
+	db.Exec("ALTER TABLE runs ADD COLUMN batch_phase INTEGER NOT NULL DEFAULT 0")
+	db.Exec("ALTER TABLE forms ADD COLUMN group_run_mode TEXT NOT NULL DEFAULT 'per_member'")
 	db.Exec("ALTER TABLE form_fields ADD COLUMN depends_on_name TEXT NOT NULL DEFAULT ''")
 	db.Exec("ALTER TABLE form_fields ADD COLUMN depends_on_operator TEXT NOT NULL DEFAULT 'eq'")
 	db.Exec("ALTER TABLE form_fields ADD COLUMN depends_on_value TEXT NOT NULL DEFAULT ''")
@@ -140,6 +197,82 @@ func New(dsn string) (*DB, error) {
 		cert_enc    TEXT NOT NULL DEFAULT '',
 		created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	)`)
+	// After the CREATEs so fresh databases get the column too.
+	db.Exec("ALTER TABLE hosts ADD COLUMN ssh_cert_id TEXT REFERENCES ssh_certs(id) ON DELETE SET NULL")
+	db.Exec("ALTER TABLE hosts ADD COLUMN labels TEXT NOT NULL DEFAULT '{}'")
+	// Inventory groups: Ansible groups of hosts with group vars and child groups.
+	db.Exec(`CREATE TABLE IF NOT EXISTS inventory_groups (
+		id          TEXT PRIMARY KEY,
+		name        TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		vars        TEXT NOT NULL DEFAULT '{}',
+		created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS inventory_group_hosts (
+		group_id TEXT NOT NULL REFERENCES inventory_groups(id) ON DELETE CASCADE,
+		host_id  TEXT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+		PRIMARY KEY (group_id, host_id)
+	)`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS inventory_group_children (
+		parent_id TEXT NOT NULL REFERENCES inventory_groups(id) ON DELETE CASCADE,
+		child_id  TEXT NOT NULL REFERENCES inventory_groups(id) ON DELETE CASCADE,
+		PRIMARY KEY (parent_id, child_id)
+	)`)
+	db.Exec("ALTER TABLE forms ADD COLUMN inventory_group_id TEXT REFERENCES inventory_groups(id) ON DELETE SET NULL")
+	db.Exec("ALTER TABLE forms ADD COLUMN host_selector TEXT NOT NULL DEFAULT ''")
+	// Server groups hold hosts; server_group_members (job runners) is only
+	// read by MigrateServerGroupMembers.
+	db.Exec(`CREATE TABLE IF NOT EXISTS server_group_hosts (
+		group_id TEXT NOT NULL REFERENCES server_groups(id) ON DELETE CASCADE,
+		host_id  TEXT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+		PRIMARY KEY (group_id, host_id)
+	)`)
+	// Inventory sources sync hosts from a git file, a runner or an HTTP
+	// endpoint; hosts.source_id records which source synced a host.
+	db.Exec(`CREATE TABLE IF NOT EXISTS inventory_sources (
+		id               TEXT PRIMARY KEY,
+		name             TEXT NOT NULL UNIQUE,
+		description      TEXT NOT NULL DEFAULT '',
+		type             TEXT NOT NULL,
+		playbook_id      TEXT REFERENCES playbooks(id) ON DELETE SET NULL,
+		path             TEXT NOT NULL DEFAULT '',
+		server_id        TEXT REFERENCES servers(id) ON DELETE SET NULL,
+		url              TEXT NOT NULL DEFAULT '',
+		token_enc        TEXT NOT NULL DEFAULT '',
+		schedule_cron    TEXT NOT NULL DEFAULT '',
+		delete_missing   INTEGER NOT NULL DEFAULT 0,
+		last_sync_at     DATETIME,
+		last_sync_status TEXT NOT NULL DEFAULT '',
+		created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS inventory_syncs (
+		id           TEXT PRIMARY KEY,
+		source_id    TEXT NOT NULL REFERENCES inventory_sources(id) ON DELETE CASCADE,
+		triggered_by TEXT NOT NULL DEFAULT 'manual',
+		status       TEXT NOT NULL,
+		created      TEXT NOT NULL DEFAULT '[]',
+		updated      TEXT NOT NULL DEFAULT '[]',
+		deleted      TEXT NOT NULL DEFAULT '[]',
+		skipped      TEXT NOT NULL DEFAULT '[]',
+		message      TEXT NOT NULL DEFAULT '',
+		started_at   DATETIME NOT NULL,
+		finished_at  DATETIME
+	)`)
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_inventory_syncs_source ON inventory_syncs(source_id, started_at)")
+	db.Exec("ALTER TABLE hosts ADD COLUMN source_id TEXT REFERENCES inventory_sources(id) ON DELETE SET NULL")
+	db.Exec(`CREATE TABLE IF NOT EXISTS host_facts (
+		host_id     TEXT PRIMARY KEY REFERENCES hosts(id) ON DELETE CASCADE,
+		facts       TEXT NOT NULL DEFAULT '{}',
+		source      TEXT NOT NULL DEFAULT 'run',
+		run_id      TEXT,
+		gathered_at DATETIME NOT NULL
+	)`)
+	// Run events name hosts by inventory name; facts are cached by looking them up.
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_hosts_name ON hosts(name)")
+	// Last reachability check of each host (ansible -m ping from a job runner).
+	db.Exec("ALTER TABLE hosts ADD COLUMN last_check_status TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE hosts ADD COLUMN last_check_at DATETIME")
+	db.Exec("ALTER TABLE hosts ADD COLUMN last_check_error TEXT NOT NULL DEFAULT ''")
 
 	// Migrate users table: add 'editor' role and email column.
 	// PRAGMA legacy_alter_table = ON prevents SQLite from rewriting FK references
@@ -221,16 +354,26 @@ func (db *DB) EnsureDefaultAdmin() error {
 
 func (db *DB) Users() *UserStore               { return &UserStore{db: db.conn} }
 func (db *DB) Servers() *ServerStore           { return &ServerStore{db: db.conn} }
-func (db *DB) Playbooks() *PlaybookStore       { return &PlaybookStore{db: db.conn} }
 func (db *DB) Forms() *FormStore               { return &FormStore{db: db.conn} }
 func (db *DB) Runs() *RunStore                 { return &RunStore{db: db.conn} }
+func (db *DB) RunEvents() *RunEventStore       { return &RunEventStore{db: db.conn} }
+func (db *DB) RunQueue() *RunQueueStore        { return &RunQueueStore{db: db.conn} }
+func (db *DB) Batches() *BatchStore            { return &BatchStore{db: db.conn} }
 func (db *DB) Audit() *AuditStore              { return &AuditStore{db: db.conn} }
 func (db *DB) ServerGroups() *ServerGroupStore { return &ServerGroupStore{db: db.conn} }
 func (db *DB) Vaults(secret string) *VaultStore {
 	return newVaultStore(db.conn, secret)
 }
+func (db *DB) Playbooks(secret string) *PlaybookStore {
+	return newPlaybookStore(db.conn, secret)
+}
 func (db *DB) Settings() *SettingsStore            { return &SettingsStore{db: db.conn} }
 func (db *DB) Hosts() *HostStore                   { return &HostStore{db: db.conn} }
+func (db *DB) InventoryGroups() *InventoryGroupStore { return &InventoryGroupStore{db: db.conn} }
+func (db *DB) HostFacts() *HostFactsStore            { return &HostFactsStore{db: db.conn} }
+func (db *DB) InventorySources(secret string) *InventorySourceStore {
+	return newInventorySourceStore(db.conn, secret)
+}
 func (db *DB) SSHCerts(secret string) *SSHCertStore {
 	return newSSHCertStore(db.conn, secret)
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -13,15 +14,16 @@ type FormStore struct {
 	db *sql.DB
 }
 
-const formSelect = "SELECT id, name, description, playbook_id, playbook_path, server_id, host_id, server_group_id, vault_id, is_quick_action, image_name, schedule_cron, schedule_enabled, webhook_token, notify_webhook, notify_email, status, created_at, updated_at FROM forms"
+const formSelect = "SELECT id, name, description, playbook_id, playbook_path, server_id, host_id, server_group_id, inventory_group_id, host_selector, vault_id, is_quick_action, image_name, schedule_cron, schedule_enabled, schedule_check_mode, schedule_diff_mode, run_options, overridable_options, max_concurrent_runs, max_runtime_minutes, rollout, group_run_mode, webhook_token, notify_webhook, notify_email, status, created_at, updated_at FROM forms"
 
 func scanForm(row interface {
 	Scan(...any) error
 }) (*models.Form, error) {
 	f := &models.Form{}
-	var isQuickAction, scheduleEnabled int
+	var isQuickAction, scheduleEnabled, scheduleCheckMode, scheduleDiffMode int
 	var serverID, hostID, serverGroupID sql.NullString
-	err := row.Scan(&f.ID, &f.Name, &f.Description, &f.PlaybookID, &f.PlaybookPath, &serverID, &hostID, &serverGroupID, &f.VaultID, &isQuickAction, &f.ImageName, &f.ScheduleCron, &scheduleEnabled, &f.WebhookToken, &f.NotifyWebhook, &f.NotifyEmail, &f.Status, &f.CreatedAt, &f.UpdatedAt)
+	var runOptions, overridable, rollout string
+	err := row.Scan(&f.ID, &f.Name, &f.Description, &f.PlaybookID, &f.PlaybookPath, &serverID, &hostID, &serverGroupID, &f.InventoryGroupID, &f.HostSelector, &f.VaultID, &isQuickAction, &f.ImageName, &f.ScheduleCron, &scheduleEnabled, &scheduleCheckMode, &scheduleDiffMode, &runOptions, &overridable, &f.MaxConcurrentRuns, &f.MaxRuntimeMinutes, &rollout, &f.GroupRunMode, &f.WebhookToken, &f.NotifyWebhook, &f.NotifyEmail, &f.Status, &f.CreatedAt, &f.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +38,20 @@ func scanForm(row interface {
 	}
 	f.IsQuickAction = isQuickAction == 1
 	f.ScheduleEnabled = scheduleEnabled == 1
+	f.ScheduleCheckMode = scheduleCheckMode == 1
+	f.ScheduleDiffMode = scheduleDiffMode == 1
+	if err := json.Unmarshal([]byte(runOptions), &f.RunOptions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(overridable), &f.OverridableOptions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(rollout), &f.Rollout); err != nil {
+		return nil, err
+	}
+	if f.OverridableOptions == nil {
+		f.OverridableOptions = []string{}
+	}
 	return f, nil
 }
 
@@ -159,7 +175,7 @@ func (s *FormStore) GetFields(formID string) ([]models.FormField, error) {
 	return fields, rows.Err()
 }
 
-func (s *FormStore) Create(name, description, playbookID, playbookPath string, serverID *string, hostID *string, serverGroupID *string, vaultID *string, isQuickAction bool, scheduleCron string, scheduleEnabled bool, notifyWebhook, notifyEmail string, fields []models.FormField) (*models.Form, error) {
+func (s *FormStore) Create(name, description, playbookID, playbookPath string, serverID *string, hostID *string, serverGroupID *string, inventoryGroupID *string, hostSelector string, vaultID *string, isQuickAction bool, scheduleCron string, scheduleEnabled, scheduleCheckMode, scheduleDiffMode bool, runOptions models.RunOptions, overridableOptions []string, maxConcurrentRuns, maxRuntimeMinutes int, rollout models.Rollout, groupRunMode string, notifyWebhook, notifyEmail string, fields []models.FormField) (*models.Form, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
@@ -168,27 +184,44 @@ func (s *FormStore) Create(name, description, playbookID, playbookPath string, s
 
 	now := time.Now()
 	f := &models.Form{
-		ID:              uuid.New().String(),
-		Name:            name,
-		Description:     description,
-		PlaybookID:      playbookID,
-		PlaybookPath:    playbookPath,
-		ServerID:        serverID,
-		HostID:          hostID,
-		ServerGroupID:   serverGroupID,
-		VaultID:         vaultID,
-		IsQuickAction:   isQuickAction,
-		ScheduleCron:    scheduleCron,
-		ScheduleEnabled: scheduleEnabled,
-		NotifyWebhook:   notifyWebhook,
-		NotifyEmail:     notifyEmail,
-		CreatedAt:       now,
-		UpdatedAt:       now,
+		ID:                 uuid.New().String(),
+		Name:               name,
+		Description:        description,
+		PlaybookID:         playbookID,
+		PlaybookPath:       playbookPath,
+		ServerID:           serverID,
+		HostID:             hostID,
+		ServerGroupID:      serverGroupID,
+		InventoryGroupID:   inventoryGroupID,
+		HostSelector:       hostSelector,
+		VaultID:            vaultID,
+		IsQuickAction:      isQuickAction,
+		ScheduleCron:       scheduleCron,
+		ScheduleEnabled:    scheduleEnabled,
+		ScheduleCheckMode:  scheduleCheckMode,
+		ScheduleDiffMode:   scheduleDiffMode,
+		RunOptions:         runOptions,
+		OverridableOptions: overridableOptions,
+		MaxConcurrentRuns:  maxConcurrentRuns,
+		MaxRuntimeMinutes:  maxRuntimeMinutes,
+		Rollout:            rollout,
+		GroupRunMode:       groupRunMode,
+		NotifyWebhook:      notifyWebhook,
+		NotifyEmail:        notifyEmail,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if f.OverridableOptions == nil {
+		f.OverridableOptions = []string{}
 	}
+	runOptionsJSON, _ := json.Marshal(f.RunOptions)
+	overridableJSON, _ := json.Marshal(f.OverridableOptions)
+	rolloutJSON, _ := json.Marshal(f.Rollout)
 
 	_, err = tx.Exec(
-		"INSERT INTO forms (id, name, description, playbook_id, playbook_path, server_id, host_id, server_group_id, vault_id, is_quick_action, image_path, image_name, schedule_cron, schedule_enabled, webhook_token, notify_webhook, notify_email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '', '', ?, ?, '', ?, ?, ?, ?)",
-		f.ID, f.Name, f.Description, f.PlaybookID, f.PlaybookPath, f.ServerID, f.HostID, f.ServerGroupID, f.VaultID, boolToInt(f.IsQuickAction), f.ScheduleCron, boolToInt(f.ScheduleEnabled), f.NotifyWebhook, f.NotifyEmail, f.CreatedAt, f.UpdatedAt,
+		"INSERT INTO forms (id, name, description, playbook_id, playbook_path, server_id, host_id, server_group_id, inventory_group_id, host_selector, vault_id, is_quick_action, image_path, image_name, schedule_cron, schedule_enabled, schedule_check_mode, schedule_diff_mode, run_options, overridable_options, max_concurrent_runs, max_runtime_minutes, rollout, group_run_mode, webhook_token, notify_webhook, notify_email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '', '', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '', ?, ?, ?, ?)",
+		f.ID, f.Name, f.Description, f.PlaybookID, f.PlaybookPath, f.ServerID, f.HostID, f.ServerGroupID, f.InventoryGroupID, f.HostSelector, f.VaultID, boolToInt(f.IsQuickAction), f.ScheduleCron, boolToInt(f.ScheduleEnabled), boolToInt(f.ScheduleCheckMode), boolToInt(f.ScheduleDiffMode), string(runOptionsJSON), string(overridableJSON), f.MaxConcurrentRuns, f.MaxRuntimeMinutes, string(rolloutJSON), f.GroupRunMode, f.NotifyWebhook, f.NotifyEmail, f.CreatedAt, f.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -211,16 +244,23 @@ func (s *FormStore) Create(name, description, playbookID, playbookPath string, s
 	return f, tx.Commit()
 }
 
-func (s *FormStore) Update(id, name, description, playbookID, playbookPath string, serverID *string, hostID *string, serverGroupID *string, vaultID *string, isQuickAction bool, scheduleCron string, scheduleEnabled bool, notifyWebhook, notifyEmail string, fields []models.FormField) (*models.Form, error) {
+func (s *FormStore) Update(id, name, description, playbookID, playbookPath string, serverID *string, hostID *string, serverGroupID *string, inventoryGroupID *string, hostSelector string, vaultID *string, isQuickAction bool, scheduleCron string, scheduleEnabled, scheduleCheckMode, scheduleDiffMode bool, runOptions models.RunOptions, overridableOptions []string, maxConcurrentRuns, maxRuntimeMinutes int, rollout models.Rollout, groupRunMode string, notifyWebhook, notifyEmail string, fields []models.FormField) (*models.Form, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if overridableOptions == nil {
+		overridableOptions = []string{}
+	}
+	runOptionsJSON, _ := json.Marshal(runOptions)
+	overridableJSON, _ := json.Marshal(overridableOptions)
+	rolloutJSON, _ := json.Marshal(rollout)
+
 	_, err = tx.Exec(
-		"UPDATE forms SET name=?, description=?, playbook_id=?, playbook_path=?, server_id=?, host_id=?, server_group_id=?, vault_id=?, is_quick_action=?, schedule_cron=?, schedule_enabled=?, notify_webhook=?, notify_email=?, updated_at=? WHERE id=?",
-		name, description, playbookID, playbookPath, serverID, hostID, serverGroupID, vaultID, boolToInt(isQuickAction), scheduleCron, boolToInt(scheduleEnabled), notifyWebhook, notifyEmail, time.Now(), id,
+		"UPDATE forms SET name=?, description=?, playbook_id=?, playbook_path=?, server_id=?, host_id=?, server_group_id=?, inventory_group_id=?, host_selector=?, vault_id=?, is_quick_action=?, schedule_cron=?, schedule_enabled=?, schedule_check_mode=?, schedule_diff_mode=?, run_options=?, overridable_options=?, max_concurrent_runs=?, max_runtime_minutes=?, rollout=?, group_run_mode=?, notify_webhook=?, notify_email=?, updated_at=? WHERE id=?",
+		name, description, playbookID, playbookPath, serverID, hostID, serverGroupID, inventoryGroupID, hostSelector, vaultID, boolToInt(isQuickAction), scheduleCron, boolToInt(scheduleEnabled), boolToInt(scheduleCheckMode), boolToInt(scheduleDiffMode), string(runOptionsJSON), string(overridableJSON), maxConcurrentRuns, maxRuntimeMinutes, string(rolloutJSON), groupRunMode, notifyWebhook, notifyEmail, time.Now(), id,
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+// HostFactsStore caches the latest ansible_facts of each host.
+type HostFactsStore struct {
+	db *sql.DB
+}
+
+// Get returns a host's cached facts, or nil when none have been seen.
+func (s *HostFactsStore) Get(hostID string) (*models.HostFacts, error) {
+	f := &models.HostFacts{}
+	var factsJSON string
+	err := s.db.QueryRow(
+		"SELECT host_id, facts, source, run_id, gathered_at FROM host_facts WHERE host_id = ?", hostID,
+	).Scan(&f.HostID, &factsJSON, &f.Source, &f.RunID, &f.GatheredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Facts = map[string]interface{}{}
+	if err := json.Unmarshal([]byte(factsJSON), &f.Facts); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Save replaces a host's cached facts. runID is nil for facts gathered
+// outside a run.
+func (s *HostFactsStore) Save(hostID string, facts map[string]interface{}, source string, runID *string) (*models.HostFacts, error) {
+	if facts == nil {
+		facts = map[string]interface{}{}
+	}
+	factsJSON, err := json.Marshal(facts)
+	if err != nil {
+		return nil, err
+	}
+	f := &models.HostFacts{HostID: hostID, Facts: facts, Source: source, RunID: runID, GatheredAt: time.Now()}
+	_, err = s.db.Exec(
+		`INSERT INTO host_facts (host_id, facts, source, run_id, gathered_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(host_id) DO UPDATE SET facts=excluded.facts, source=excluded.source, run_id=excluded.run_id, gathered_at=excluded.gathered_at`,
+		f.HostID, string(factsJSON), f.Source, f.RunID, f.GatheredAt,
+	)
+	return f, err
+}
+
+// HostIDsMatching returns the IDs of the hosts whose cached fact at path (a
+// JSON path such as $.ansible_os_family) equals value, compared as text.
+// A value containing * or ? is matched as a glob, e.g. "8.*".
+func (s *HostFactsStore) HostIDsMatching(path, value string) (map[string]bool, error) {
+	op := "="
+	if strings.ContainsAny(value, "*?") {
+		op = "GLOB"
+	}
+	rows, err := s.db.Query(
+		"SELECT host_id FROM host_facts WHERE CAST(json_extract(facts, ?) AS TEXT) "+op+" ?", path, value,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
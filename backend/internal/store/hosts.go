@@ -14,10 +14,31 @@ type HostStore struct {
 	db *sql.DB
 }
 
+// hostCols are the columns scanHost reads, from hosts aliased as h.
+const hostCols = `h.id, h.name, h.address, h.description, h.ssh_cert_id, h.vars, h.labels, h.source_id,
+	h.last_check_status, h.last_check_at, h.last_check_error, h.created_at`
+
 func (s *HostStore) List() ([]*models.Host, error) {
-	rows, err := s.db.Query(
-		"SELECT id, name, address, description, ssh_cert_id, vars, created_at FROM hosts ORDER BY name",
-	)
+	rows, err := s.db.Query("SELECT " + hostCols + " FROM hosts h ORDER BY h.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []*models.Host
+	for rows.Next() {
+		h, err := scanHost(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// ListByName returns the hosts with an inventory name; names are not unique.
+func (s *HostStore) ListByName(name string) ([]*models.Host, error) {
+	rows, err := s.db.Query("SELECT "+hostCols+" FROM hosts h WHERE h.name = ?", name)
 	if err != nil {
 		return nil, err
 	}
@@ -35,24 +56,28 @@ func (s *HostStore) List() ([]*models.Host, error) {
 }
 
 func (s *HostStore) Get(id string) (*models.Host, error) {
-	row := s.db.QueryRow(
-		"SELECT id, name, address, description, ssh_cert_id, vars, created_at FROM hosts WHERE id = ?", id,
-	)
-	h, err := scanHost(row.Scan)
+	h, err := scanHost(s.db.QueryRow("SELECT "+hostCols+" FROM hosts h WHERE h.id = ?", id).Scan)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
 	return h, err
 }
 
-func (s *HostStore) Create(name, address, description string, sshCertID *string, vars map[string]string) (*models.Host, error) {
+func (s *HostStore) Create(name, address, description string, sshCertID *string, vars map[string]interface{}, labels map[string]string) (*models.Host, error) {
 	if vars == nil {
-		vars = map[string]string{}
+		vars = map[string]interface{}{}
+	}
+	if labels == nil {
+		labels = map[string]string{}
 	}
 	varsJSON, err := json.Marshal(vars)
 	if err != nil {
 		return nil, err
 	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
 	h := &models.Host{
 		ID:          uuid.New().String(),
 		Name:        name,
@@ -60,26 +85,34 @@ func (s *HostStore) Create(name, address, description string, sshCertID *string,
 		Description: description,
 		SSHCertID:   sshCertID,
 		Vars:        vars,
+		Labels:      labels,
 		CreatedAt:   time.Now(),
 	}
 	_, err = s.db.Exec(
-		"INSERT INTO hosts (id, name, address, description, ssh_cert_id, vars, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		h.ID, h.Name, h.Address, h.Description, h.SSHCertID, string(varsJSON), h.CreatedAt,
+		"INSERT INTO hosts (id, name, address, description, ssh_cert_id, vars, labels, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		h.ID, h.Name, h.Address, h.Description, h.SSHCertID, string(varsJSON), string(labelsJSON), h.CreatedAt,
 	)
 	return h, err
 }
 
-func (s *HostStore) Update(id, name, address, description string, sshCertID *string, vars map[string]string) (*models.Host, error) {
+func (s *HostStore) Update(id, name, address, description string, sshCertID *string, vars map[string]interface{}, labels map[string]string) (*models.Host, error) {
 	if vars == nil {
-		vars = map[string]string{}
+		vars = map[string]interface{}{}
+	}
+	if labels == nil {
+		labels = map[string]string{}
 	}
 	varsJSON, err := json.Marshal(vars)
 	if err != nil {
 		return nil, err
 	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
 	_, err = s.db.Exec(
-		"UPDATE hosts SET name=?, address=?, description=?, ssh_cert_id=?, vars=? WHERE id=?",
-		name, address, description, sshCertID, string(varsJSON), id,
+		"UPDATE hosts SET name=?, address=?, description=?, ssh_cert_id=?, vars=?, labels=? WHERE id=?",
+		name, address, description, sshCertID, string(varsJSON), string(labelsJSON), id,
 	)
 	if err != nil {
 		return nil, err
@@ -87,6 +120,23 @@ func (s *HostStore) Update(id, name, address, description string, sshCertID *str
 	return s.Get(id)
 }
 
+// SetSource records the inventory source that synced a host; nil marks it
+// as managed by hand.
+func (s *HostStore) SetSource(id string, sourceID *string) error {
+	_, err := s.db.Exec("UPDATE hosts SET source_id=? WHERE id=?", sourceID, id)
+	return err
+}
+
+// SetCheck records the outcome of a reachability check of a host: ok,
+// unreachable or failed, with Ansible's message when it was not ok.
+func (s *HostStore) SetCheck(id, status, message string, at time.Time) error {
+	_, err := s.db.Exec(
+		"UPDATE hosts SET last_check_status=?, last_check_at=?, last_check_error=? WHERE id=?",
+		status, at, message, id,
+	)
+	return err
+}
+
 func (s *HostStore) Delete(id string) error {
 	_, err := s.db.Exec("DELETE FROM hosts WHERE id = ?", id)
 	return err
@@ -95,15 +145,22 @@ func (s *HostStore) Delete(id string) error {
 // scanHost decodes a row using the provided scan function (handles both *sql.Row and *sql.Rows).
 func scanHost(scan func(...any) error) (*models.Host, error) {
 	h := &models.Host{}
-	var varsJSON string
-	if err := scan(&h.ID, &h.Name, &h.Address, &h.Description, &h.SSHCertID, &varsJSON, &h.CreatedAt); err != nil {
+	var varsJSON, labelsJSON string
+	if err := scan(&h.ID, &h.Name, &h.Address, &h.Description, &h.SSHCertID, &varsJSON, &labelsJSON, &h.SourceID,
+		&h.LastCheckStatus, &h.LastCheckAt, &h.LastCheckError, &h.CreatedAt); err != nil {
 		return nil, err
 	}
-	h.Vars = map[string]string{}
+	h.Vars = map[string]interface{}{}
 	if varsJSON != "" && varsJSON != "null" {
 		if err := json.Unmarshal([]byte(varsJSON), &h.Vars); err != nil {
 			return nil, err
 		}
 	}
+	h.Labels = map[string]string{}
+	if labelsJSON != "" && labelsJSON != "null" {
+		if err := json.Unmarshal([]byte(labelsJSON), &h.Labels); err != nil {
+			return nil, err
+		}
+	}
 	return h, nil
 }
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/google/uuid"
+)
+
+type InventoryGroupStore struct {
+	db *sql.DB
+}
+
+const inventoryGroupSelect = "SELECT id, name, description, vars, created_at FROM inventory_groups"
+
+// List returns every inventory group with its hosts and child groups.
+func (s *InventoryGroupStore) List() ([]*models.InventoryGroup, error) {
+	rows, err := s.db.Query(inventoryGroupSelect + " ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	var groups []*models.InventoryGroup
+	byID := map[string]*models.InventoryGroup{}
+	for rows.Next() {
+		g, err := scanInventoryGroup(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, g)
+		byID[g.ID] = g
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadMembers(byID, ""); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *InventoryGroupStore) Get(id string) (*models.InventoryGroup, error) {
+	g, err := scanInventoryGroup(s.db.QueryRow(inventoryGroupSelect+" WHERE id = ?", id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, s.loadMembers(map[string]*models.InventoryGroup{g.ID: g}, g.ID)
+}
+
+// GetByName returns the group with an Ansible group name, or nil.
+func (s *InventoryGroupStore) GetByName(name string) (*models.InventoryGroup, error) {
+	var id string
+	err := s.db.QueryRow("SELECT id FROM inventory_groups WHERE name = ?", name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+func (s *InventoryGroupStore) Create(name, description string, vars map[string]interface{}, hostIDs, childIDs []string) (*models.InventoryGroup, error) {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	varsJSON, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	g := &models.InventoryGroup{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		Vars:        vars,
+		CreatedAt:   time.Now(),
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		"INSERT INTO inventory_groups (id, name, description, vars, created_at) VALUES (?, ?, ?, ?, ?)",
+		g.ID, g.Name, g.Description, string(varsJSON), g.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := setInventoryGroupMembers(tx, g.ID, hostIDs, childIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(g.ID)
+}
+
+// Update replaces a group's fields, hosts and child groups.
+func (s *InventoryGroupStore) Update(id, name, description string, vars map[string]interface{}, hostIDs, childIDs []string) (*models.InventoryGroup, error) {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	varsJSON, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("UPDATE inventory_groups SET name=?, description=?, vars=? WHERE id=?", name, description, string(varsJSON), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	if _, err := tx.Exec("DELETE FROM inventory_group_hosts WHERE group_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM inventory_group_children WHERE parent_id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := setInventoryGroupMembers(tx, id, hostIDs, childIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// AddMembers adds hosts and child groups to a group, keeping the ones it has.
+func (s *InventoryGroupStore) AddMembers(id string, hostIDs, childIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := setInventoryGroupMembers(tx, id, hostIDs, childIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *InventoryGroupStore) Delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM inventory_groups WHERE id = ?", id)
+	return err
+}
+
+func setInventoryGroupMembers(tx *sql.Tx, id string, hostIDs, childIDs []string) error {
+	for _, hid := range hostIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO inventory_group_hosts (group_id, host_id) VALUES (?, ?)", id, hid); err != nil {
+			return err
+		}
+	}
+	for _, cid := range childIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO inventory_group_children (parent_id, child_id) VALUES (?, ?)", id, cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadMembers fills in HostIDs and ChildIDs of groups, all of them when only
+// is "" or else just that group's.
+func (s *InventoryGroupStore) loadMembers(groups map[string]*models.InventoryGroup, only string) error {
+	for _, g := range groups {
+		g.HostIDs, g.ChildIDs = []string{}, []string{}
+	}
+	load := func(query string, add func(g *models.InventoryGroup, id string)) error {
+		rows, err := s.db.Query(query, only, only)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var gid, id string
+			if err := rows.Scan(&gid, &id); err != nil {
+				return err
+			}
+			if g := groups[gid]; g != nil {
+				add(g, id)
+			}
+		}
+		return rows.Err()
+	}
+	if err := load(`SELECT m.group_id, m.host_id FROM inventory_group_hosts m JOIN hosts h ON h.id = m.host_id
+		WHERE ? = '' OR m.group_id = ? ORDER BY h.name`,
+		func(g *models.InventoryGroup, id string) { g.HostIDs = append(g.HostIDs, id) }); err != nil {
+		return err
+	}
+	return load(`SELECT c.parent_id, c.child_id FROM inventory_group_children c JOIN inventory_groups g ON g.id = c.child_id
+		WHERE ? = '' OR c.parent_id = ? ORDER BY g.name`,
+		func(g *models.InventoryGroup, id string) { g.ChildIDs = append(g.ChildIDs, id) })
+}
+
+func scanInventoryGroup(scan func(...any) error) (*models.InventoryGroup, error) {
+	g := &models.InventoryGroup{}
+	var varsJSON string
+	if err := scan(&g.ID, &g.Name, &g.Description, &varsJSON, &g.CreatedAt); err != nil {
+		return nil, err
+	}
+	g.Vars = map[string]interface{}{}
+	if varsJSON != "" && varsJSON != "null" {
+		if err := json.Unmarshal([]byte(varsJSON), &g.Vars); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
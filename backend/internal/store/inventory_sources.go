@@ -0,0 +1,244 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/google/uuid"
+)
+
+// InventorySourceStore persists inventory sources and their sync history.
+// HTTP bearer tokens are encrypted at rest like playbook Galaxy tokens.
+type InventorySourceStore struct {
+	db  *sql.DB
+	key [32]byte
+}
+
+func newInventorySourceStore(db *sql.DB, secret string) *InventorySourceStore {
+	return &InventorySourceStore{db: db, key: sha256.Sum256([]byte(secret))}
+}
+
+const inventorySourceCols = `id, name, description, type, playbook_id, path, server_id, url, token_enc != '',
+	schedule_cron, delete_missing, last_sync_at, last_sync_status, created_at`
+
+func scanInventorySource(scan func(...any) error) (*models.InventorySource, error) {
+	src := &models.InventorySource{}
+	err := scan(&src.ID, &src.Name, &src.Description, &src.Type, &src.PlaybookID, &src.Path, &src.ServerID, &src.URL, &src.HasToken,
+		&src.ScheduleCron, &src.DeleteMissing, &src.LastSyncAt, &src.LastSyncStatus, &src.CreatedAt)
+	return src, err
+}
+
+func (s *InventorySourceStore) List() ([]*models.InventorySource, error) {
+	rows, err := s.db.Query("SELECT " + inventorySourceCols + " FROM inventory_sources ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*models.InventorySource
+	for rows.Next() {
+		src, err := scanInventorySource(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, src)
+	}
+	return list, rows.Err()
+}
+
+func (s *InventorySourceStore) Get(id string) (*models.InventorySource, error) {
+	src, err := scanInventorySource(s.db.QueryRow("SELECT "+inventorySourceCols+" FROM inventory_sources WHERE id = ?", id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return src, err
+}
+
+// GetByName returns the source with a name, or nil.
+func (s *InventorySourceStore) GetByName(name string) (*models.InventorySource, error) {
+	src, err := scanInventorySource(s.db.QueryRow("SELECT "+inventorySourceCols+" FROM inventory_sources WHERE name = ?", name).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return src, err
+}
+
+// GetDecryptedToken returns the plaintext HTTP bearer token of a source, or
+// "" when none is set. Used only while syncing.
+func (s *InventorySourceStore) GetDecryptedToken(id string) (string, error) {
+	var enc string
+	err := s.db.QueryRow("SELECT token_enc FROM inventory_sources WHERE id = ?", id).Scan(&enc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("inventory source not found")
+	}
+	if err != nil || enc == "" {
+		return "", err
+	}
+	return decryptString(s.key, enc)
+}
+
+func (s *InventorySourceStore) Create(name, description, typ string, playbookID *string, path string, serverID *string, url, token, scheduleCron string, deleteMissing bool) (*models.InventorySource, error) {
+	var tokenEnc string
+	if token != "" {
+		var err error
+		if tokenEnc, err = encryptString(s.key, token); err != nil {
+			return nil, fmt.Errorf("encrypt token: %w", err)
+		}
+	}
+	id := uuid.New().String()
+	_, err := s.db.Exec(
+		`INSERT INTO inventory_sources (id, name, description, type, playbook_id, path, server_id, url, token_enc, schedule_cron, delete_missing, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, name, description, typ, playbookID, path, serverID, url, tokenEnc, scheduleCron, deleteMissing, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Update saves a source. An empty token keeps the stored one unless the
+// source no longer syncs over HTTP, in which case the token is dropped.
+func (s *InventorySourceStore) Update(id, name, description, typ string, playbookID *string, path string, serverID *string, url, token, scheduleCron string, deleteMissing bool) (*models.InventorySource, error) {
+	res, err := s.db.Exec(
+		`UPDATE inventory_sources SET name=?, description=?, type=?, playbook_id=?, path=?, server_id=?, url=?, schedule_cron=?, delete_missing=?
+		 WHERE id=?`,
+		name, description, typ, playbookID, path, serverID, url, scheduleCron, deleteMissing, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	switch {
+	case token != "":
+		enc, err := encryptString(s.key, token)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt token: %w", err)
+		}
+		if _, err := s.db.Exec("UPDATE inventory_sources SET token_enc=? WHERE id=?", enc, id); err != nil {
+			return nil, err
+		}
+	case typ != "http":
+		if _, err := s.db.Exec("UPDATE inventory_sources SET token_enc='' WHERE id=?", id); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(id)
+}
+
+// Delete removes a source and its sync history. Hosts it synced stay, as
+// hosts managed by hand.
+func (s *InventorySourceStore) Delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM inventory_sources WHERE id = ?", id)
+	return err
+}
+
+// StartSync records a running sync of a source. It fails when the source
+// already has one running.
+func (s *InventorySourceStore) StartSync(sourceID, trigger string) (*models.InventorySync, error) {
+	now := time.Now()
+	res, err := s.db.Exec(
+		"UPDATE inventory_sources SET last_sync_status='running', last_sync_at=? WHERE id=? AND last_sync_status != 'running'",
+		now, sourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("inventory source is already syncing")
+	}
+	sync := &models.InventorySync{
+		ID:        uuid.New().String(),
+		SourceID:  sourceID,
+		Trigger:   trigger,
+		Status:    "running",
+		Created:   []string{},
+		Updated:   []string{},
+		Deleted:   []string{},
+		Skipped:   []string{},
+		StartedAt: now,
+	}
+	_, err = s.db.Exec(
+		"INSERT INTO inventory_syncs (id, source_id, triggered_by, status, started_at) VALUES (?, ?, ?, ?, ?)",
+		sync.ID, sync.SourceID, sync.Trigger, sync.Status, sync.StartedAt,
+	)
+	return sync, err
+}
+
+// FinishSync saves the outcome of a sync and the source's last sync status.
+func (s *InventorySourceStore) FinishSync(sync *models.InventorySync) error {
+	now := time.Now()
+	sync.FinishedAt = &now
+	lists := make([]string, 0, 4)
+	for _, l := range [][]string{sync.Created, sync.Updated, sync.Deleted, sync.Skipped} {
+		if l == nil {
+			l = []string{}
+		}
+		b, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		lists = append(lists, string(b))
+	}
+	if _, err := s.db.Exec(
+		"UPDATE inventory_syncs SET status=?, created=?, updated=?, deleted=?, skipped=?, message=?, finished_at=? WHERE id=?",
+		sync.Status, lists[0], lists[1], lists[2], lists[3], sync.Message, now, sync.ID,
+	); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("UPDATE inventory_sources SET last_sync_status=? WHERE id=?", sync.Status, sync.SourceID)
+	return err
+}
+
+// FailRunningSyncs marks syncs the last process left running as failed.
+func (s *InventorySourceStore) FailRunningSyncs() error {
+	if _, err := s.db.Exec(
+		"UPDATE inventory_syncs SET status='failed', message='interrupted by a server restart', finished_at=? WHERE status='running'",
+		time.Now(),
+	); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("UPDATE inventory_sources SET last_sync_status='failed' WHERE last_sync_status='running'")
+	return err
+}
+
+// ListSyncs returns the most recent syncs of a source, newest first.
+func (s *InventorySourceStore) ListSyncs(sourceID string, limit int) ([]*models.InventorySync, error) {
+	rows, err := s.db.Query(
+		`SELECT id, source_id, triggered_by, status, created, updated, deleted, skipped, message, started_at, finished_at
+		 FROM inventory_syncs WHERE source_id = ? ORDER BY started_at DESC LIMIT ?`,
+		sourceID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*models.InventorySync
+	for rows.Next() {
+		sync := &models.InventorySync{}
+		var created, updated, deleted, skipped string
+		if err := rows.Scan(&sync.ID, &sync.SourceID, &sync.Trigger, &sync.Status, &created, &updated, &deleted, &skipped,
+			&sync.Message, &sync.StartedAt, &sync.FinishedAt); err != nil {
+			return nil, err
+		}
+		for _, f := range []struct {
+			raw string
+			dst *[]string
+		}{{created, &sync.Created}, {updated, &sync.Updated}, {deleted, &sync.Deleted}, {skipped, &sync.Skipped}} {
+			*f.dst = []string{}
+			if err := json.Unmarshal([]byte(f.raw), f.dst); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, sync)
+	}
+	return list, rows.Err()
+}
@@ -1,25 +1,34 @@
 package store
 
 import (
+	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/models"
 	"github.com/google/uuid"
 )
 
+// PlaybookStore persists playbook sources. Galaxy server tokens are encrypted
+// at rest with the same key derivation as vault passwords.
 type PlaybookStore struct {
-	db *sql.DB
+	db  *sql.DB
+	key [32]byte
 }
 
-const playbookCols = "id, name, description, repo_url, branch, token, created_at"
+func newPlaybookStore(db *sql.DB, secret string) *PlaybookStore {
+	return &PlaybookStore{db: db, key: sha256.Sum256([]byte(secret))}
+}
+
+const playbookCols = "id, name, description, repo_url, branch, token, galaxy_server_url, galaxy_token_enc != '', created_at"
 
 func scanPlaybook(row interface {
 	Scan(...any) error
 }) (*models.Playbook, error) {
 	p := &models.Playbook{}
-	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.RepoURL, &p.Branch, &p.Token, &p.CreatedAt)
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.RepoURL, &p.Branch, &p.Token, &p.GalaxyServerURL, &p.HasGalaxyToken, &p.CreatedAt)
 	return p, err
 }
 
@@ -49,31 +58,70 @@ func (s *PlaybookStore) Get(id string) (*models.Playbook, error) {
 	return p, err
 }
 
-func (s *PlaybookStore) Create(name, description, repoURL, branch, token string) (*models.Playbook, error) {
+// GetDecryptedGalaxyToken returns the plaintext Galaxy server token for a
+// source, or "" when none is set. Used only at run time.
+func (s *PlaybookStore) GetDecryptedGalaxyToken(id string) (string, error) {
+	var enc string
+	err := s.db.QueryRow("SELECT galaxy_token_enc FROM playbooks WHERE id = ?", id).Scan(&enc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("playbook source not found")
+	}
+	if err != nil || enc == "" {
+		return "", err
+	}
+	return decryptString(s.key, enc)
+}
+
+func (s *PlaybookStore) Create(name, description, repoURL, branch, token, galaxyServerURL, galaxyToken string) (*models.Playbook, error) {
+	var galaxyTokenEnc string
+	if galaxyToken != "" {
+		var err error
+		if galaxyTokenEnc, err = encryptString(s.key, galaxyToken); err != nil {
+			return nil, fmt.Errorf("encrypt galaxy token: %w", err)
+		}
+	}
 	p := &models.Playbook{
-		ID:          uuid.New().String(),
-		Name:        name,
-		Description: description,
-		RepoURL:     repoURL,
-		Branch:      branch,
-		Token:       token,
-		CreatedAt:   time.Now(),
+		ID:              uuid.New().String(),
+		Name:            name,
+		Description:     description,
+		RepoURL:         repoURL,
+		Branch:          branch,
+		Token:           token,
+		GalaxyServerURL: galaxyServerURL,
+		HasGalaxyToken:  galaxyTokenEnc != "",
+		CreatedAt:       time.Now(),
 	}
 	_, err := s.db.Exec(
-		"INSERT INTO playbooks (id, name, description, repo_url, branch, token, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		p.ID, p.Name, p.Description, p.RepoURL, p.Branch, p.Token, p.CreatedAt,
+		"INSERT INTO playbooks (id, name, description, repo_url, branch, token, galaxy_server_url, galaxy_token_enc, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		p.ID, p.Name, p.Description, p.RepoURL, p.Branch, p.Token, p.GalaxyServerURL, galaxyTokenEnc, p.CreatedAt,
 	)
 	return p, err
 }
 
-func (s *PlaybookStore) Update(id, name, description, repoURL, branch, token string) (*models.Playbook, error) {
+// Update saves a playbook source. An empty galaxyToken keeps the stored one,
+// unless galaxyServerURL is also empty, in which case the token is dropped.
+func (s *PlaybookStore) Update(id, name, description, repoURL, branch, token, galaxyServerURL, galaxyToken string) (*models.Playbook, error) {
 	_, err := s.db.Exec(
-		"UPDATE playbooks SET name=?, description=?, repo_url=?, branch=?, token=? WHERE id=?",
-		name, description, repoURL, branch, token, id,
+		"UPDATE playbooks SET name=?, description=?, repo_url=?, branch=?, token=?, galaxy_server_url=? WHERE id=?",
+		name, description, repoURL, branch, token, galaxyServerURL, id,
 	)
 	if err != nil {
 		return nil, err
 	}
+	switch {
+	case galaxyToken != "":
+		enc, err := encryptString(s.key, galaxyToken)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt galaxy token: %w", err)
+		}
+		if _, err := s.db.Exec("UPDATE playbooks SET galaxy_token_enc=? WHERE id=?", enc, id); err != nil {
+			return nil, err
+		}
+	case galaxyServerURL == "":
+		if _, err := s.db.Exec("UPDATE playbooks SET galaxy_token_enc='' WHERE id=?", id); err != nil {
+			return nil, err
+		}
+	}
 	return s.Get(id)
 }
 
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+	"github.com/google/uuid"
+)
+
+// RunEventStore records the plays, tasks and per-host results of runs as
+// reported by the events callback plugin.
+type RunEventStore struct {
+	db *sql.DB
+}
+
+func (s *RunEventStore) AddPlay(runID, name string, startedAt time.Time) (*models.RunPlay, error) {
+	p := &models.RunPlay{ID: uuid.New().String(), RunID: runID, Name: name, StartedAt: startedAt, Tasks: []*models.RunTask{}}
+	_, err := s.db.Exec("INSERT INTO run_plays (id, run_id, name, started_at) VALUES (?, ?, ?, ?)", p.ID, p.RunID, p.Name, p.StartedAt)
+	return p, err
+}
+
+func (s *RunEventStore) AddTask(runID, playID, name, action string, handler bool, startedAt time.Time) (*models.RunTask, error) {
+	t := &models.RunTask{ID: uuid.New().String(), RunID: runID, PlayID: playID, Name: name, Action: action, Handler: handler, StartedAt: startedAt, Results: []*models.RunHostResult{}}
+	_, err := s.db.Exec(
+		"INSERT INTO run_tasks (id, run_id, play_id, name, action, handler, started_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		t.ID, t.RunID, t.PlayID, t.Name, t.Action, boolToInt(t.Handler), t.StartedAt,
+	)
+	return t, err
+}
+
+func (s *RunEventStore) AddHostResult(r *models.RunHostResult) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO run_host_results (id, run_id, task_id, host, status, action, msg, duration, ignore_errors, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.ID, r.RunID, r.TaskID, r.Host, r.Status, r.Action, r.Msg, r.Duration, boolToInt(r.IgnoreErrors), r.CreatedAt,
+	)
+	return err
+}
+
+// ListPlays returns a run's plays in order, each with its tasks and their
+// host results.
+func (s *RunEventStore) ListPlays(runID string) ([]*models.RunPlay, error) {
+	plays := []*models.RunPlay{}
+	playByID := map[string]*models.RunPlay{}
+	rows, err := s.db.Query("SELECT id, run_id, name, started_at FROM run_plays WHERE run_id = ? ORDER BY rowid", runID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		p := &models.RunPlay{Tasks: []*models.RunTask{}}
+		if err := rows.Scan(&p.ID, &p.RunID, &p.Name, &p.StartedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		plays = append(plays, p)
+		playByID[p.ID] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	taskByID := map[string]*models.RunTask{}
+	rows, err = s.db.Query("SELECT id, run_id, play_id, name, action, handler, started_at FROM run_tasks WHERE run_id = ? ORDER BY rowid", runID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		t := &models.RunTask{Results: []*models.RunHostResult{}}
+		var handler int
+		if err := rows.Scan(&t.ID, &t.RunID, &t.PlayID, &t.Name, &t.Action, &handler, &t.StartedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.Handler = handler == 1
+		if p := playByID[t.PlayID]; p != nil {
+			p.Tasks = append(p.Tasks, t)
+		}
+		taskByID[t.ID] = t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results, err := s.listResults("WHERE run_id = ? ORDER BY rowid", runID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if t := taskByID[r.TaskID]; t != nil {
+			t.Results = append(t.Results, r)
+		}
+	}
+	return plays, nil
+}
+
+// HostSummaries totals a run's results per host, with the failed and
+// unreachable tasks listed for each.
+func (s *RunEventStore) HostSummaries(runID string) ([]*models.RunHostSummary, error) {
+	taskNames := map[string]string{}
+	rows, err := s.db.Query("SELECT id, name FROM run_tasks WHERE run_id = ?", runID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taskNames[id] = name
+	}
+	rows.Close()
+
+	results, err := s.listResults("WHERE run_id = ? ORDER BY host, rowid", runID)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []*models.RunHostSummary{}
+	var cur *models.RunHostSummary
+	for _, r := range results {
+		if cur == nil || cur.Host != r.Host {
+			cur = &models.RunHostSummary{Host: r.Host, Failures: []models.RunHostFailure{}}
+			summaries = append(summaries, cur)
+		}
+		switch r.Status {
+		case "ok":
+			cur.OK++
+		case "changed":
+			cur.Changed++
+		case "skipped":
+			cur.Skipped++
+		case "failed", "unreachable":
+			if r.Status == "failed" {
+				cur.Failed++
+			} else {
+				cur.Unreachable++
+			}
+			cur.Failures = append(cur.Failures, models.RunHostFailure{
+				TaskID: r.TaskID, Task: taskNames[r.TaskID], Status: r.Status, Msg: r.Msg, IgnoreErrors: r.IgnoreErrors,
+			})
+		}
+	}
+	return summaries, nil
+}
+
+func (s *RunEventStore) listResults(where string, args ...interface{}) ([]*models.RunHostResult, error) {
+	rows, err := s.db.Query("SELECT id, run_id, task_id, host, status, action, msg, duration, ignore_errors, created_at FROM run_host_results "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []*models.RunHostResult
+	for rows.Next() {
+		r := &models.RunHostResult{}
+		var ignore int
+		if err := rows.Scan(&r.ID, &r.RunID, &r.TaskID, &r.Host, &r.Status, &r.Action, &r.Msg, &r.Duration, &ignore, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.IgnoreErrors = ignore == 1
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
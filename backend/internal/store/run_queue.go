@@ -0,0 +1,207 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brettjrea/ansible-frontend/internal/models"
+)
+
+// RunQueueStore holds pending runs until a worker claims them. A run's
+// queue row lives from creation until the run finishes, so a claimed row
+// whose run is still marked running after a restart identifies a run the
+// previous process never finished.
+type RunQueueStore struct {
+	db *sql.DB
+}
+
+// Enqueue adds a pending run to the back of the queue. inventory is a fixed
+// inventory for the run, or "" to build it from the form when it starts.
+func (s *RunQueueStore) Enqueue(runID, inventory string) error {
+	_, err := s.db.Exec(
+		"INSERT INTO run_queue (run_id, inventory, enqueued_at) VALUES (?, ?, ?)",
+		runID, inventory, time.Now(),
+	)
+	return err
+}
+
+// queueLimits joins a queue row q and its run r to the run's runner and
+// form limits (0 = no limit) and their current number of claimed runs.
+const queueLimits = `
+	JOIN runs r ON r.id = q.run_id
+	LEFT JOIN servers s ON s.id = r.server_id
+	LEFT JOIN forms f ON f.id = r.form_id
+	LEFT JOIN (SELECT r2.server_id AS id, COUNT(*) AS n FROM run_queue q2 JOIN runs r2 ON r2.id = q2.run_id
+		WHERE q2.claimed_at IS NOT NULL GROUP BY r2.server_id) sa ON sa.id = r.server_id
+	LEFT JOIN (SELECT r2.form_id AS id, COUNT(*) AS n FROM run_queue q2 JOIN runs r2 ON r2.id = q2.run_id
+		WHERE q2.claimed_at IS NOT NULL GROUP BY r2.form_id) fa ON fa.id = r.form_id`
+
+// Claim takes the oldest unclaimed run whose runner and form are below
+// their max_concurrent_runs. It returns an empty runID when nothing can
+// start. Runs held back by a limit keep their place in the queue.
+func (s *RunQueueStore) Claim() (runID, inventory string, err error) {
+	err = s.db.QueryRow(`UPDATE run_queue SET claimed_at = ?, attempts = attempts + 1
+		WHERE run_id = (
+			SELECT q.run_id FROM run_queue q`+queueLimits+`
+			WHERE q.claimed_at IS NULL
+				AND (COALESCE(s.max_concurrent_runs, 0) = 0 OR COALESCE(sa.n, 0) < s.max_concurrent_runs)
+				AND (COALESCE(f.max_concurrent_runs, 0) = 0 OR COALESCE(fa.n, 0) < f.max_concurrent_runs)
+			ORDER BY q.rowid LIMIT 1)
+		RETURNING run_id, inventory`, time.Now()).Scan(&runID, &inventory)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	return runID, inventory, err
+}
+
+// Done removes a finished run from the queue.
+func (s *RunQueueStore) Done(runID string) error {
+	_, err := s.db.Exec("DELETE FROM run_queue WHERE run_id = ?", runID)
+	return err
+}
+
+// Unqueue removes a run that no worker has claimed yet. It reports false
+// when the run is not waiting in the queue.
+func (s *RunQueueStore) Unqueue(runID string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM run_queue WHERE run_id = ? AND claimed_at IS NULL", runID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Positions maps each unclaimed run to its 1-based place in the queue.
+func (s *RunQueueStore) Positions() (map[string]int, error) {
+	rows, err := s.db.Query("SELECT run_id FROM run_queue WHERE claimed_at IS NULL ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pos := map[string]int{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		pos[id] = len(pos) + 1
+	}
+	return pos, rows.Err()
+}
+
+// List returns every queued and claimed run in queue order. Position, and
+// Blocked when a concurrency limit holds the run back, are set on runs that
+// have not been claimed yet.
+func (s *RunQueueStore) List() ([]*models.QueueEntry, error) {
+	rows, err := s.db.Query(`SELECT q.run_id, r.form_id, COALESCE(f.name, ''), r.server_id, r.batch_id, r.status, q.attempts, q.enqueued_at, q.claimed_at,
+		COALESCE(s.max_concurrent_runs, 0), COALESCE(sa.n, 0), COALESCE(f.max_concurrent_runs, 0), COALESCE(fa.n, 0)
+		FROM run_queue q` + queueLimits + `
+		ORDER BY q.rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.QueueEntry{}
+	pos := 0
+	for rows.Next() {
+		e := &models.QueueEntry{}
+		var serverMax, serverActive, formMax, formActive int
+		if err := rows.Scan(&e.RunID, &e.FormID, &e.FormName, &e.ServerID, &e.BatchID, &e.Status, &e.Attempts, &e.EnqueuedAt, &e.ClaimedAt,
+			&serverMax, &serverActive, &formMax, &formActive); err != nil {
+			return nil, err
+		}
+		if e.ClaimedAt == nil {
+			pos++
+			e.Position = pos
+			switch {
+			case serverMax > 0 && serverActive >= serverMax:
+				e.Blocked = fmt.Sprintf("job runner is at its limit of %d concurrent runs", serverMax)
+			case formMax > 0 && formActive >= formMax:
+				e.Blocked = fmt.Sprintf("form is at its limit of %d concurrent runs", formMax)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Recover puts the queue back in order after a restart and must run before
+// any worker starts. Claimed runs that never started are unclaimed. Runs
+// left running, and pending runs with no queue row (from before the queue
+// existed) other than those waiting for a later rollout batch, are orphans:
+// with requeue set, an orphan that has a queue row and has been claimed
+// fewer than maxAttempts times goes back in the queue with its output and
+// results cleared; every other orphan is failed with reason appended to its
+// output.
+func (s *RunQueueStore) Recover(requeue bool, maxAttempts int, reason string) (requeued, failed int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM run_queue WHERE run_id IN (SELECT id FROM runs WHERE status NOT IN ('pending', 'running'))"); err != nil {
+		return 0, 0, err
+	}
+	if _, err := tx.Exec("UPDATE run_queue SET claimed_at = NULL WHERE run_id IN (SELECT id FROM runs WHERE status = 'pending')"); err != nil {
+		return 0, 0, err
+	}
+
+	type orphan struct {
+		id       string
+		queued   bool
+		attempts int
+	}
+	rows, err := tx.Query(`SELECT r.id, q.run_id IS NOT NULL, COALESCE(q.attempts, 0)
+		FROM runs r LEFT JOIN run_queue q ON q.run_id = r.id
+		WHERE r.status = 'running' OR (r.status = 'pending' AND q.run_id IS NULL AND NOT EXISTS (
+			SELECT 1 FROM batches b WHERE b.id = r.batch_id AND r.batch_phase > b.phase))`)
+	if err != nil {
+		return 0, 0, err
+	}
+	var orphans []orphan
+	for rows.Next() {
+		var o orphan
+		if err := rows.Scan(&o.id, &o.queued, &o.attempts); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	for _, o := range orphans {
+		if requeue && o.queued && o.attempts < maxAttempts {
+			for _, q := range []string{
+				"UPDATE runs SET status = 'pending', output = '', started_at = NULL, finished_at = NULL WHERE id = ?",
+				"UPDATE run_queue SET claimed_at = NULL WHERE run_id = ?",
+				"DELETE FROM run_plays WHERE run_id = ?",
+				"DELETE FROM run_host_stats WHERE run_id = ?",
+			} {
+				if _, err := tx.Exec(q, o.id); err != nil {
+					return 0, 0, err
+				}
+			}
+			requeued++
+			continue
+		}
+		if _, err := tx.Exec(
+			"UPDATE runs SET status = 'failed', output = output || CASE WHEN output = '' THEN '' ELSE char(10) END || ?, finished_at = ? WHERE id = ?",
+			reason, now, o.id,
+		); err != nil {
+			return 0, 0, err
+		}
+		if _, err := tx.Exec("DELETE FROM run_queue WHERE run_id = ?", o.id); err != nil {
+			return 0, 0, err
+		}
+		failed++
+	}
+	return requeued, failed, tx.Commit()
+}
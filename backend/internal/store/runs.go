@@ -2,7 +2,9 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/models"
@@ -13,39 +15,114 @@ type RunStore struct {
 	db *sql.DB
 }
 
-func (s *RunStore) Create(formID *string, playbookID, serverID, variables string, batchID *string) (*models.Run, error) {
+const runCols = "id, form_id, playbook_id, server_id, variables, status, output, batch_id, batch_phase, check_mode, diff_mode, options, cancelled_by, inventory, parent_run_id, started_at, finished_at"
+
+func scanRun(row interface {
+	Scan(...any) error
+}) (*models.Run, error) {
+	r := &models.Run{}
+	var checkMode, diffMode int
+	var options string
+	if err := row.Scan(&r.ID, &r.FormID, &r.PlaybookID, &r.ServerID, &r.Variables, &r.Status, &r.Output, &r.BatchID, &r.BatchPhase, &checkMode, &diffMode, &options, &r.CancelledBy, &r.Inventory, &r.ParentRunID, &r.StartedAt, &r.FinishedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(options), &r.Options)
+	r.CheckMode = checkMode == 1
+	r.DiffMode = diffMode == 1
+	return r, nil
+}
+
+// Create records a pending run. batchPhase is the rollout batch of a
+// server-group member run and inventory its fixed inventory ("" builds it
+// from the form when the run starts); parentRunID links a relaunch to the
+// run it repeats.
+func (s *RunStore) Create(formID *string, playbookID, serverID, variables string, batchID *string, batchPhase int, parentRunID *string, inventory string, opts models.RunOptions) (*models.Run, error) {
 	r := &models.Run{
-		ID:         uuid.New().String(),
-		FormID:     formID,
-		PlaybookID: playbookID,
-		ServerID:   serverID,
-		Variables:  variables,
-		Status:     "pending",
-		Output:     "",
-		BatchID:    batchID,
+		ID:          uuid.New().String(),
+		FormID:      formID,
+		PlaybookID:  playbookID,
+		ServerID:    serverID,
+		Variables:   variables,
+		Status:      "pending",
+		Output:      "",
+		BatchID:     batchID,
+		BatchPhase:  batchPhase,
+		CheckMode:   opts.Check,
+		DiffMode:    opts.Diff,
+		Options:     opts,
+		ParentRunID: parentRunID,
+		Inventory:   inventory,
 	}
+	optJSON, _ := json.Marshal(opts)
 	_, err := s.db.Exec(
-		"INSERT INTO runs (id, form_id, playbook_id, server_id, variables, status, output, batch_id) VALUES (?, ?, ?, ?, ?, 'pending', '', ?)",
-		r.ID, r.FormID, r.PlaybookID, r.ServerID, r.Variables, r.BatchID,
+		"INSERT INTO runs (id, form_id, playbook_id, server_id, variables, status, output, batch_id, batch_phase, check_mode, diff_mode, options, inventory, parent_run_id) VALUES (?, ?, ?, ?, ?, 'pending', '', ?, ?, ?, ?, ?, ?, ?)",
+		r.ID, r.FormID, r.PlaybookID, r.ServerID, r.Variables, r.BatchID, r.BatchPhase, boolToInt(r.CheckMode), boolToInt(r.DiffMode), string(optJSON), r.Inventory, r.ParentRunID,
 	)
 	return r, err
 }
 
+// Get returns a run with its recap summary and per-host counts.
 func (s *RunStore) Get(id string) (*models.Run, error) {
-	r := &models.Run{}
-	err := s.db.QueryRow(
-		"SELECT id, form_id, playbook_id, server_id, variables, status, output, batch_id, started_at, finished_at FROM runs WHERE id = ?", id,
-	).Scan(&r.ID, &r.FormID, &r.PlaybookID, &r.ServerID, &r.Variables, &r.Status, &r.Output, &r.BatchID, &r.StartedAt, &r.FinishedAt)
+	r, err := scanRun(s.db.QueryRow("SELECT "+runCols+" FROM runs WHERE id = ?", id))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
-	return r, err
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachSummaries([]*models.Run{r}); err != nil {
+		return nil, err
+	}
+	if r.Summary != nil {
+		if r.Recap, err = s.Recap(id); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RunFilter narrows List and Count. Nil fields don't filter. Changed,
+// Failed and Unreachable match runs whose PLAY RECAP has (true) or lacks
+// (false) a host with that count above zero; runs without a recap only
+// match false.
+type RunFilter struct {
+	Status      string
+	Changed     *bool
+	Failed      *bool
+	Unreachable *bool
+}
+
+func (f RunFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, f.Status)
+	}
+	recap := func(col string, want *bool) {
+		if want == nil {
+			return
+		}
+		cond := "EXISTS (SELECT 1 FROM run_host_stats st WHERE st.run_id = runs.id AND st." + col + " > 0)"
+		if !*want {
+			cond = "NOT " + cond
+		}
+		conds = append(conds, cond)
+	}
+	recap("changed", f.Changed)
+	recap("failed", f.Failed)
+	recap("unreachable", f.Unreachable)
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
 }
 
-// List returns runs ordered by newest first. Pass limit=0 for all rows.
-func (s *RunStore) List(limit, offset int) ([]*models.Run, error) {
-	q := "SELECT id, form_id, playbook_id, server_id, variables, status, output, batch_id, started_at, finished_at FROM runs ORDER BY rowid DESC"
-	args := []interface{}{}
+// List returns runs matching f ordered by newest first, each with its recap
+// summary. Pass limit=0 for all rows.
+func (s *RunStore) List(f RunFilter, limit, offset int) ([]*models.Run, error) {
+	where, args := f.where()
+	q := "SELECT " + runCols + " FROM runs" + where + " ORDER BY rowid DESC"
 	if limit > 0 {
 		q += " LIMIT ? OFFSET ?"
 		args = append(args, limit, offset)
@@ -58,21 +135,138 @@ func (s *RunStore) List(limit, offset int) ([]*models.Run, error) {
 
 	var runs []*models.Run
 	for rows.Next() {
-		r := &models.Run{}
-		if err := rows.Scan(&r.ID, &r.FormID, &r.PlaybookID, &r.ServerID, &r.Variables, &r.Status, &r.Output, &r.BatchID, &r.StartedAt, &r.FinishedAt); err != nil {
+		r, err := scanRun(rows)
+		if err != nil {
 			return nil, err
 		}
 		runs = append(runs, r)
 	}
-	return runs, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return runs, s.attachSummaries(runs)
 }
 
-func (s *RunStore) Count() (int, error) {
+// ListBatch returns a batch's member runs in rollout order, each with its
+// recap summary.
+func (s *RunStore) ListBatch(batchID string) ([]*models.Run, error) {
+	rows, err := s.db.Query("SELECT "+runCols+" FROM runs WHERE batch_id = ? ORDER BY batch_phase, rowid", batchID)
+	if err != nil {
+		return nil, err
+	}
+	runs := []*models.Run{}
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return runs, s.attachSummaries(runs)
+}
+
+func (s *RunStore) Count(f RunFilter) (int, error) {
+	where, args := f.where()
 	var n int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&n)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM runs"+where, args...).Scan(&n)
 	return n, err
 }
 
+// SetRecap replaces the PLAY RECAP counts stored for a run.
+func (s *RunStore) SetRecap(id string, stats []models.RunHostStats) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM run_host_stats WHERE run_id = ?", id); err != nil {
+		return err
+	}
+	for _, st := range stats {
+		if _, err := tx.Exec(
+			"INSERT INTO run_host_stats (run_id, host, ok, changed, unreachable, failed, skipped, rescued, ignored) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			id, st.Host, st.OK, st.Changed, st.Unreachable, st.Failed, st.Skipped, st.Rescued, st.Ignored,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Recap returns a run's PLAY RECAP counts ordered by host.
+func (s *RunStore) Recap(id string) ([]models.RunHostStats, error) {
+	rows, err := s.db.Query("SELECT host, ok, changed, unreachable, failed, skipped, rescued, ignored FROM run_host_stats WHERE run_id = ? ORDER BY host", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []models.RunHostStats{}
+	for rows.Next() {
+		var st models.RunHostStats
+		if err := rows.Scan(&st.Host, &st.OK, &st.Changed, &st.Unreachable, &st.Failed, &st.Skipped, &st.Rescued, &st.Ignored); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// FailedHosts returns the hosts a run's PLAY RECAP counts as failed or
+// unreachable, sorted by name.
+func (s *RunStore) FailedHosts(id string) ([]string, error) {
+	rows, err := s.db.Query("SELECT host FROM run_host_stats WHERE run_id = ? AND (failed > 0 OR unreachable > 0) ORDER BY host", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hosts := []string{}
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, rows.Err()
+}
+
+// attachSummaries sets Summary on each run that has a recorded recap.
+func (s *RunStore) attachSummaries(runs []*models.Run) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.Run, len(runs))
+	placeholders := make([]string, len(runs))
+	args := make([]interface{}, len(runs))
+	for i, r := range runs {
+		byID[r.ID] = r
+		placeholders[i] = "?"
+		args[i] = r.ID
+	}
+	rows, err := s.db.Query(`SELECT run_id, COUNT(*), SUM(ok), SUM(changed), SUM(unreachable), SUM(failed), SUM(skipped), SUM(rescued), SUM(ignored),
+		SUM(changed > 0), SUM(failed > 0), SUM(unreachable > 0)
+		FROM run_host_stats WHERE run_id IN (`+strings.Join(placeholders, ",")+`) GROUP BY run_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		sum := &models.RunSummary{}
+		if err := rows.Scan(&id, &sum.Hosts, &sum.OK, &sum.Changed, &sum.Unreachable, &sum.Failed, &sum.Skipped, &sum.Rescued, &sum.Ignored,
+			&sum.ChangedHosts, &sum.FailedHosts, &sum.UnreachableHosts); err != nil {
+			return err
+		}
+		byID[id].Summary = sum
+	}
+	return rows.Err()
+}
+
 func (s *RunStore) SetRunning(id string) error {
 	t := time.Now()
 	_, err := s.db.Exec("UPDATE runs SET status='running', started_at=? WHERE id=?", t, id)
@@ -92,3 +286,23 @@ func (s *RunStore) Finish(id, status, output string) error {
 	)
 	return err
 }
+
+// SetCancelledBy records the user who cancelled a run.
+func (s *RunStore) SetCancelledBy(id, username string) error {
+	_, err := s.db.Exec("UPDATE runs SET cancelled_by = ? WHERE id = ?", username, id)
+	return err
+}
+
+// CancelHeld cancels a pending run that has no queue row, such as a member
+// waiting for a later rollout batch. It reports whether the run was held.
+func (s *RunStore) CancelHeld(id, output string) (bool, error) {
+	res, err := s.db.Exec(
+		"UPDATE runs SET status = 'cancelled', output = ?, finished_at = ? WHERE id = ? AND status = 'pending' AND NOT EXISTS (SELECT 1 FROM run_queue WHERE run_id = runs.id)",
+		output, time.Now(), id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
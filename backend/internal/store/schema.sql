@@ -34,6 +34,8 @@ CREATE TABLE IF NOT EXISTS playbooks (
     repo_url    TEXT NOT NULL DEFAULT '',
     branch      TEXT NOT NULL DEFAULT 'main',
     token       TEXT NOT NULL DEFAULT '',
+    galaxy_server_url TEXT NOT NULL DEFAULT '',
+    galaxy_token_enc  TEXT NOT NULL DEFAULT '',
     created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -54,6 +56,8 @@ CREATE TABLE IF NOT EXISTS server_groups (
     created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Job-runner members of server groups from before groups held hosts; moved
+-- to server_group_hosts (created in db.go) by MigrateServerGroupMembers.
 CREATE TABLE IF NOT EXISTS server_group_members (
     group_id  TEXT NOT NULL REFERENCES server_groups(id) ON DELETE CASCADE,
     server_id TEXT NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
@@ -70,12 +74,22 @@ CREATE TABLE IF NOT EXISTS forms (
     playbook_path    TEXT NOT NULL DEFAULT '',
     server_id        TEXT REFERENCES servers(id) ON DELETE CASCADE,
     server_group_id  TEXT REFERENCES server_groups(id) ON DELETE SET NULL,
+    inventory_group_id TEXT REFERENCES inventory_groups(id) ON DELETE SET NULL,
+    host_selector    TEXT NOT NULL DEFAULT '',
     vault_id         TEXT REFERENCES vaults(id) ON DELETE SET NULL,
     is_quick_action  INTEGER NOT NULL DEFAULT 0,
     image_path       TEXT NOT NULL DEFAULT '',
     image_name       TEXT NOT NULL DEFAULT '',
     schedule_cron    TEXT NOT NULL DEFAULT '',
     schedule_enabled INTEGER NOT NULL DEFAULT 0,
+    schedule_check_mode INTEGER NOT NULL DEFAULT 0,
+    schedule_diff_mode  INTEGER NOT NULL DEFAULT 0,
+    run_options         TEXT NOT NULL DEFAULT '{}',
+    overridable_options TEXT NOT NULL DEFAULT '[]',
+    max_concurrent_runs INTEGER NOT NULL DEFAULT 0,
+    max_runtime_minutes INTEGER NOT NULL DEFAULT 0,
+    rollout             TEXT NOT NULL DEFAULT '{}',
+    group_run_mode      TEXT NOT NULL DEFAULT 'per_member',
     webhook_token    TEXT NOT NULL DEFAULT '',
     notify_webhook   TEXT NOT NULL DEFAULT '',
     notify_email     TEXT NOT NULL DEFAULT '',
@@ -105,13 +119,92 @@ CREATE TABLE IF NOT EXISTS runs (
     playbook_id TEXT NOT NULL REFERENCES playbooks(id),
     server_id   TEXT NOT NULL REFERENCES servers(id),
     variables   TEXT NOT NULL DEFAULT '{}',
-    status      TEXT NOT NULL CHECK(status IN ('pending','running','success','failed')) DEFAULT 'pending',
+    status      TEXT NOT NULL CHECK(status IN ('pending','running','success','failed','cancelled','timed_out')) DEFAULT 'pending',
     output      TEXT NOT NULL DEFAULT '',
     batch_id    TEXT,
+    batch_phase INTEGER NOT NULL DEFAULT 0, -- rollout batch within batch_id, from 1
+    check_mode  INTEGER NOT NULL DEFAULT 0,
+    diff_mode   INTEGER NOT NULL DEFAULT 0,
+    options     TEXT NOT NULL DEFAULT '{}',
+    cancelled_by TEXT, -- username of whoever cancelled the run
+    inventory   TEXT NOT NULL DEFAULT '', -- fixed inventory (server-group member runs); '' builds it from the form
+    parent_run_id TEXT REFERENCES runs(id) ON DELETE SET NULL, -- the run this one relaunched
     started_at  DATETIME,
     finished_at DATETIME
 );
 
+-- Runs waiting for, or claimed by, a queue worker. Rows are removed once
+-- the run finishes.
+CREATE TABLE IF NOT EXISTS run_queue (
+    run_id      TEXT PRIMARY KEY REFERENCES runs(id) ON DELETE CASCADE,
+    inventory   TEXT NOT NULL DEFAULT '', -- fixed inventory (server-group member runs); '' builds it from the form
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    enqueued_at DATETIME NOT NULL,
+    claimed_at  DATETIME
+);
+
+-- Server-group launches: one run per member (runs.batch_id), started in
+-- rollout batches of the form's batch size.
+CREATE TABLE IF NOT EXISTS batches (
+    id            TEXT PRIMARY KEY,
+    form_id       TEXT REFERENCES forms(id) ON DELETE SET NULL,
+    rollout       TEXT NOT NULL DEFAULT '{}', -- the form's rollout settings at launch
+    status        TEXT NOT NULL DEFAULT 'running',
+    phase         INTEGER NOT NULL DEFAULT 0, -- rollout batch running or last finished
+    phases        INTEGER NOT NULL DEFAULT 1,
+    message       TEXT NOT NULL DEFAULT '',
+    next_phase_at DATETIME,
+    created_at    DATETIME NOT NULL,
+    finished_at   DATETIME
+);
+
+-- Per-host counts parsed from a run's PLAY RECAP.
+CREATE TABLE IF NOT EXISTS run_host_stats (
+    run_id      TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    host        TEXT NOT NULL,
+    ok          INTEGER NOT NULL DEFAULT 0,
+    changed     INTEGER NOT NULL DEFAULT 0,
+    unreachable INTEGER NOT NULL DEFAULT 0,
+    failed      INTEGER NOT NULL DEFAULT 0,
+    skipped     INTEGER NOT NULL DEFAULT 0,
+    rescued     INTEGER NOT NULL DEFAULT 0,
+    ignored     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (run_id, host)
+);
+
+-- Structured results recorded from the events callback plugin.
+CREATE TABLE IF NOT EXISTS run_plays (
+    id         TEXT PRIMARY KEY,
+    run_id     TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL DEFAULT '',
+    started_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS run_tasks (
+    id         TEXT PRIMARY KEY,
+    run_id     TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    play_id    TEXT NOT NULL REFERENCES run_plays(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL DEFAULT '',
+    action     TEXT NOT NULL DEFAULT '',
+    handler    INTEGER NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_run_tasks_run ON run_tasks(run_id);
+
+CREATE TABLE IF NOT EXISTS run_host_results (
+    id            TEXT PRIMARY KEY,
+    run_id        TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    task_id       TEXT NOT NULL REFERENCES run_tasks(id) ON DELETE CASCADE,
+    host          TEXT NOT NULL,
+    status        TEXT NOT NULL CHECK(status IN ('ok','changed','failed','skipped','unreachable')),
+    action        TEXT NOT NULL DEFAULT '',
+    msg           TEXT NOT NULL DEFAULT '',
+    duration      REAL,
+    ignore_errors INTEGER NOT NULL DEFAULT 0,
+    created_at    DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_run_host_results_run ON run_host_results(run_id, host);
+
 CREATE TABLE IF NOT EXISTS settings (
     key   TEXT PRIMARY KEY,
     value TEXT NOT NULL DEFAULT ''
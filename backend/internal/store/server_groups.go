@@ -3,6 +3,7 @@ package store
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/brettjrea/ansible-frontend/internal/models"
@@ -68,45 +69,132 @@ func (s *ServerGroupStore) Delete(id string) error {
 	return err
 }
 
-// GetMembers returns the servers that belong to a server group.
-func (s *ServerGroupStore) GetMembers(groupID string) ([]*models.Server, error) {
+// GetMembers returns the hosts that belong to a server group.
+func (s *ServerGroupStore) GetMembers(groupID string) ([]*models.Host, error) {
 	rows, err := s.db.Query(`
-		SELECT s.id, s.name, s.host, s.port, s.username, s.ssh_private_key, s.pre_command, s.created_at
-		FROM servers s
-		JOIN server_group_members m ON s.id = m.server_id
+		SELECT `+hostCols+`
+		FROM hosts h
+		JOIN server_group_hosts m ON h.id = m.host_id
 		WHERE m.group_id = ?
-		ORDER BY s.name`, groupID)
+		ORDER BY h.name`, groupID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var servers []*models.Server
+	var hosts []*models.Host
 	for rows.Next() {
-		sv := &models.Server{}
-		if err := rows.Scan(&sv.ID, &sv.Name, &sv.Host, &sv.Port, &sv.Username, &sv.SSHPrivateKey, &sv.PreCommand, &sv.CreatedAt); err != nil {
+		h, err := scanHost(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
-		servers = append(servers, sv)
+		hosts = append(hosts, h)
 	}
-	return servers, rows.Err()
+	return hosts, rows.Err()
 }
 
-// SetMembers replaces the server group's member list.
-func (s *ServerGroupStore) SetMembers(groupID string, serverIDs []string) error {
+// SetMembers replaces the server group's member hosts.
+func (s *ServerGroupStore) SetMembers(groupID string, hostIDs []string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("DELETE FROM server_group_members WHERE group_id = ?", groupID); err != nil {
+	if _, err := tx.Exec("DELETE FROM server_group_hosts WHERE group_id = ?", groupID); err != nil {
 		return err
 	}
-	for _, sid := range serverIDs {
-		if _, err := tx.Exec("INSERT INTO server_group_members (group_id, server_id) VALUES (?, ?)", groupID, sid); err != nil {
+	for _, hid := range hostIDs {
+		if _, err := tx.Exec("INSERT INTO server_group_hosts (group_id, host_id) VALUES (?, ?)", groupID, hid); err != nil {
 			return err
 		}
 	}
 	return tx.Commit()
 }
+
+// MigrateServerGroupMembers moves server group members that are still job
+// runners (server_group_members, from before groups held hosts) onto hosts.
+// Each runner becomes the host whose address or name is the runner's host,
+// or else a new host named after the runner's host with ansible_port and
+// ansible_user from the runner and its SSH key as a new SSH cert. Migrated
+// rows are removed, so later calls have nothing to do. It returns how many
+// members were moved.
+func (db *DB) MigrateServerGroupMembers(secret string) (int, error) {
+	type legacyMember struct {
+		groupID string
+		server  models.Server
+	}
+	rows, err := db.conn.Query(`
+		SELECT m.group_id, s.id, s.name, s.host, s.port, s.username, s.ssh_private_key
+		FROM server_group_members m
+		JOIN servers s ON s.id = m.server_id`)
+	if err != nil {
+		return 0, err
+	}
+	var legacy []legacyMember
+	for rows.Next() {
+		var m legacyMember
+		if err := rows.Scan(&m.groupID, &m.server.ID, &m.server.Name, &m.server.Host, &m.server.Port, &m.server.Username, &m.server.SSHPrivateKey); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		legacy = append(legacy, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(legacy) == 0 {
+		return 0, err
+	}
+
+	hostStore, certStore := db.Hosts(), db.SSHCerts(secret)
+	hosts, err := hostStore.List()
+	if err != nil {
+		return 0, err
+	}
+	hostFor := map[string]string{} // server ID -> host ID
+	for _, m := range legacy {
+		sv := m.server
+		hostID, ok := hostFor[sv.ID]
+		if !ok {
+			for _, h := range hosts {
+				if h.Address == sv.Host || h.Name == sv.Host {
+					hostID = h.ID
+					break
+				}
+			}
+		}
+		if hostID == "" {
+			vars := map[string]interface{}{}
+			if sv.Port != 0 && sv.Port != 22 {
+				vars["ansible_port"] = sv.Port
+			}
+			if sv.Username != "" {
+				vars["ansible_user"] = sv.Username
+			}
+			var certID *string
+			if key := strings.TrimSpace(sv.SSHPrivateKey); key != "" {
+				cert, err := certStore.Create(sv.Name+" key", "Migrated from job runner "+sv.Name)
+				if err != nil {
+					return 0, err
+				}
+				if err := certStore.SetCert(cert.ID, "id_"+sv.Name, []byte(key+"\n")); err != nil {
+					return 0, err
+				}
+				certID = &cert.ID
+			}
+			h, err := hostStore.Create(sv.Host, sv.Host, "Migrated from job runner "+sv.Name, certID, vars, nil)
+			if err != nil {
+				return 0, err
+			}
+			hosts = append(hosts, h)
+			hostID = h.ID
+		}
+		hostFor[sv.ID] = hostID
+		if _, err := db.conn.Exec("INSERT OR IGNORE INTO server_group_hosts (group_id, host_id) VALUES (?, ?)", m.groupID, hostID); err != nil {
+			return 0, err
+		}
+		if _, err := db.conn.Exec("DELETE FROM server_group_members WHERE group_id = ? AND server_id = ?", m.groupID, sv.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(legacy), nil
+}
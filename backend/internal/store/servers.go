@@ -14,7 +14,7 @@ type ServerStore struct {
 }
 
 func (s *ServerStore) List() ([]*models.Server, error) {
-	rows, err := s.db.Query("SELECT id, name, host, port, username, pre_command, execution_environment, created_at FROM servers ORDER BY name")
+	rows, err := s.db.Query("SELECT id, name, host, port, username, pre_command, execution_environment, runner_type, host_key, host_ca_keys, jump_server_id, max_concurrent_runs, created_at FROM servers ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +23,7 @@ func (s *ServerStore) List() ([]*models.Server, error) {
 	var servers []*models.Server
 	for rows.Next() {
 		sv := &models.Server{}
-		if err := rows.Scan(&sv.ID, &sv.Name, &sv.Host, &sv.Port, &sv.Username, &sv.PreCommand, &sv.ExecutionEnvironment, &sv.CreatedAt); err != nil {
+		if err := rows.Scan(&sv.ID, &sv.Name, &sv.Host, &sv.Port, &sv.Username, &sv.PreCommand, &sv.ExecutionEnvironment, &sv.RunnerType, &sv.HostKey, &sv.HostCAKeys, &sv.JumpServerID, &sv.MaxConcurrentRuns, &sv.CreatedAt); err != nil {
 			return nil, err
 		}
 		servers = append(servers, sv)
@@ -34,15 +34,15 @@ func (s *ServerStore) List() ([]*models.Server, error) {
 func (s *ServerStore) Get(id string) (*models.Server, error) {
 	sv := &models.Server{}
 	err := s.db.QueryRow(
-		"SELECT id, name, host, port, username, ssh_private_key, pre_command, execution_environment, created_at FROM servers WHERE id = ?", id,
-	).Scan(&sv.ID, &sv.Name, &sv.Host, &sv.Port, &sv.Username, &sv.SSHPrivateKey, &sv.PreCommand, &sv.ExecutionEnvironment, &sv.CreatedAt)
+		"SELECT id, name, host, port, username, ssh_private_key, pre_command, execution_environment, runner_type, host_key, host_ca_keys, jump_server_id, max_concurrent_runs, created_at FROM servers WHERE id = ?", id,
+	).Scan(&sv.ID, &sv.Name, &sv.Host, &sv.Port, &sv.Username, &sv.SSHPrivateKey, &sv.PreCommand, &sv.ExecutionEnvironment, &sv.RunnerType, &sv.HostKey, &sv.HostCAKeys, &sv.JumpServerID, &sv.MaxConcurrentRuns, &sv.CreatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
 	return sv, err
 }
 
-func (s *ServerStore) Create(name, host string, port int, username, sshKey, preCommand, executionEnvironment string) (*models.Server, error) {
+func (s *ServerStore) Create(name, host string, port int, username, sshKey, preCommand, executionEnvironment, runnerType, hostKey, hostCAKeys string, jumpServerID *string, maxConcurrentRuns int) (*models.Server, error) {
 	sv := &models.Server{
 		ID:                   uuid.New().String(),
 		Name:                 name,
@@ -52,37 +52,60 @@ func (s *ServerStore) Create(name, host string, port int, username, sshKey, preC
 		SSHPrivateKey:        sshKey,
 		PreCommand:           preCommand,
 		ExecutionEnvironment: executionEnvironment,
+		RunnerType:           runnerType,
+		HostKey:              hostKey,
+		HostCAKeys:           hostCAKeys,
+		JumpServerID:         jumpServerID,
+		MaxConcurrentRuns:    maxConcurrentRuns,
 		CreatedAt:            time.Now(),
 	}
 	_, err := s.db.Exec(
-		"INSERT INTO servers (id, name, host, port, username, ssh_private_key, pre_command, execution_environment, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		sv.ID, sv.Name, sv.Host, sv.Port, sv.Username, sv.SSHPrivateKey, sv.PreCommand, sv.ExecutionEnvironment, sv.CreatedAt,
+		"INSERT INTO servers (id, name, host, port, username, ssh_private_key, pre_command, execution_environment, runner_type, host_key, host_ca_keys, jump_server_id, max_concurrent_runs, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sv.ID, sv.Name, sv.Host, sv.Port, sv.Username, sv.SSHPrivateKey, sv.PreCommand, sv.ExecutionEnvironment, sv.RunnerType, sv.HostKey, sv.HostCAKeys, sv.JumpServerID, sv.MaxConcurrentRuns, sv.CreatedAt,
 	)
 	sv.SSHPrivateKey = "" // don't return key
 	return sv, err
 }
 
-func (s *ServerStore) Update(id, name, host string, port int, username, sshKey, preCommand, executionEnvironment string) (*models.Server, error) {
+// Update saves a server. An empty sshKey keeps the stored key; a nil
+// hostKey or hostCAKeys keeps the stored value.
+func (s *ServerStore) Update(id, name, host string, port int, username, sshKey, preCommand, executionEnvironment, runnerType string, hostKey, hostCAKeys, jumpServerID *string, maxConcurrentRuns int) (*models.Server, error) {
 	if sshKey != "" {
 		_, err := s.db.Exec(
-			"UPDATE servers SET name=?, host=?, port=?, username=?, ssh_private_key=?, pre_command=?, execution_environment=? WHERE id=?",
-			name, host, port, username, sshKey, preCommand, executionEnvironment, id,
+			"UPDATE servers SET name=?, host=?, port=?, username=?, ssh_private_key=?, pre_command=?, execution_environment=?, runner_type=?, jump_server_id=?, max_concurrent_runs=? WHERE id=?",
+			name, host, port, username, sshKey, preCommand, executionEnvironment, runnerType, jumpServerID, maxConcurrentRuns, id,
 		)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		_, err := s.db.Exec(
-			"UPDATE servers SET name=?, host=?, port=?, username=?, pre_command=?, execution_environment=? WHERE id=?",
-			name, host, port, username, preCommand, executionEnvironment, id,
+			"UPDATE servers SET name=?, host=?, port=?, username=?, pre_command=?, execution_environment=?, runner_type=?, jump_server_id=?, max_concurrent_runs=? WHERE id=?",
+			name, host, port, username, preCommand, executionEnvironment, runnerType, jumpServerID, maxConcurrentRuns, id,
 		)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if hostKey != nil {
+		if _, err := s.db.Exec("UPDATE servers SET host_key=? WHERE id=?", *hostKey, id); err != nil {
+			return nil, err
+		}
+	}
+	if hostCAKeys != nil {
+		if _, err := s.db.Exec("UPDATE servers SET host_ca_keys=? WHERE id=?", *hostCAKeys, id); err != nil {
+			return nil, err
+		}
+	}
 	return s.Get(id)
 }
 
+// SetHostKey pins a server's SSH host key.
+func (s *ServerStore) SetHostKey(id, hostKey string) error {
+	_, err := s.db.Exec("UPDATE servers SET host_key=? WHERE id=?", hostKey, id)
+	return err
+}
+
 func (s *ServerStore) Delete(id string) error {
 	_, err := s.db.Exec("DELETE FROM servers WHERE id = ?", id)
 	return err
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/brettjrea/ansible-frontend/internal/api"
 	"github.com/brettjrea/ansible-frontend/internal/auth"
@@ -46,9 +47,35 @@ func main() {
 	}
 	jwtSvc := auth.NewJWTService(jwtSecret)
 
+	// Server groups used to hold job runners; move their members onto hosts.
+	if n, err := db.MigrateServerGroupMembers(jwtSecret); err != nil {
+		log.Fatal("migrate server group members:", err)
+	} else if n > 0 {
+		log.Printf("Moved %d server group member(s) from job runners to hosts", n)
+	}
+
 	// Runs handler + scheduler (created before NewRouter to avoid circular deps)
 	vaultStoreForRuns := db.Vaults(jwtSecret)
-	runsH := api.NewRunsHandler(db.Runs(), db.Forms(), db.Servers(), db.ServerGroups(), db.Playbooks(), vaultStoreForRuns, db.Hosts(), db.SSHCerts(jwtSecret), db.Audit(), jwtSvc)
+	runsH := api.NewRunsHandler(db.Runs(), db.RunEvents(), db.Forms(), db.Servers(), db.ServerGroups(), db.Playbooks(jwtSecret), vaultStoreForRuns, db.Hosts(), db.InventoryGroups(), db.SSHCerts(jwtSecret), db.HostFacts(), db.Audit(), jwtSvc, db.RunQueue(), db.Batches())
+
+	// Run queue: settle runs the last process left unfinished, pick up
+	// unfinished server-group rollouts, then start the workers that execute
+	// queued runs.
+	if err := runsH.RecoverQueue(os.Getenv("RUN_RECOVERY")); err != nil {
+		log.Fatal("recover run queue:", err)
+	}
+	if err := runsH.ResumeBatches(); err != nil {
+		log.Fatal("resume batches:", err)
+	}
+	workers := 4
+	if v := os.Getenv("RUN_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatal("RUN_WORKERS must be a positive number")
+		}
+		workers = n
+	}
+	runsH.StartWorkers(workers)
 
 	sched := scheduler.New(runsH.TriggerScheduledRun)
 	defer sched.Stop()
@@ -63,13 +90,25 @@ func main() {
 	sshCertsH := api.NewSSHCertsHandler(db.SSHCerts(jwtSecret), db.Audit())
 
 	// Hosts handler
-	hostsH := api.NewHostsHandler(db.Hosts(), db.Audit())
+	hostsH := api.NewHostsHandler(db.Hosts(), db.InventoryGroups(), db.SSHCerts(jwtSecret), db.HostFacts(), db.Audit())
+
+	// Inventory sources: settle interrupted syncs and schedule the rest.
+	inventorySourcesH := api.NewInventorySourcesHandler(db.InventorySources(jwtSecret), db.Hosts(), db.Playbooks(jwtSecret), db.Servers(), db.Audit(), sched)
+	if err := inventorySourcesH.ScheduleAll(); err != nil {
+		log.Fatal("schedule inventory sources:", err)
+	}
+
+	// Host ping: reachability checks from job runners, and the scheduled fleet ping.
+	hostPingH := api.NewHostPingHandler(db.Hosts(), db.Servers(), db.SSHCerts(jwtSecret), db.Settings(), db.Audit(), sched)
+	if err := hostPingH.ScheduleFleetPing(); err != nil {
+		log.Fatal("schedule fleet ping:", err)
+	}
 
 	// EE Editor handler (GitHub Contents API proxy)
 	eeH := api.NewEEEditorHandler(db.Settings())
 
 	// Router
-	router := api.NewRouter(db, jwtSvc, "./data/vaults", "./data/form-images", jwtSecret, runsH, sched, sshCertsH, hostsH, eeH)
+	router := api.NewRouter(db, jwtSvc, "./data/vaults", "./data/form-images", jwtSecret, runsH, sched, sshCertsH, hostsH, inventorySourcesH, hostPingH, eeH)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -1,6 +1,6 @@
 import { get } from 'svelte/store';
 import { authStore } from './stores';
-import type { AuditLog, AppSettings, AuthResponse, EEFiles, EmailSettings, GitHubSettings, Form, FormField, Host, Playbook, Run, Server, ServerGroup, SSHCert, User, Vault, VarSuggestion } from './types';
+import type { AuditLog, AppSettings, AuthResponse, Batch, EEFiles, EmailSettings, GitHubSettings, Form, FormField, Host, HostFacts, HostImportResult, HostPingResult, HostPingSettings, InventoryGroup, InventorySource, InventorySourceWrite, InventorySync, Playbook, QueueStatus, Run, RunHostSummary, RunOptions, RunPlay, Server, ServerTestResult, ServerGroup, SSHCert, User, Vault, VarSuggestion } from './types';
 
 export class ApiError extends Error {
 	constructor(public status: number, message: string) {
@@ -67,6 +67,27 @@ async function requestPaged<T>(path: string, options: RequestInit = {}): Promise
 	return { data, total };
 }
 
+// Like request<T> but returns the response body as a Blob, for downloads.
+async function requestBlob(path: string): Promise<Blob> {
+	const { token } = get(authStore);
+	const headers: Record<string, string> = {};
+	if (token) {
+		headers['Authorization'] = `Bearer ${token}`;
+	}
+
+	const res = await fetch(`/api${path}`, { headers });
+
+	if (res.status === 401) {
+		authStore.logout();
+		throw new ApiError(401, 'Session expired');
+	}
+	if (!res.ok) {
+		const body = await res.json().catch(() => ({ error: 'Request failed' }));
+		throw new ApiError(res.status, body.error || 'Request failed');
+	}
+	return res.blob();
+}
+
 export const auth = {
 	login: (username: string, password: string) =>
 		request<AuthResponse>('/auth/login', { method: 'POST', body: JSON.stringify({ username, password }) }),
@@ -95,15 +116,15 @@ export const servers = {
 		request<Server>(`/servers/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
 	delete: (id: string) => request<void>(`/servers/${id}`, { method: 'DELETE' }),
 	test: (id: string) =>
-		request<{ success: boolean; message: string }>(`/servers/${id}/test`, { method: 'POST' }),
+		request<ServerTestResult>(`/servers/${id}/test`, { method: 'POST' }),
 };
 
 export const playbooks = {
 	list: () => request<Playbook[]>('/playbooks'),
 	get: (id: string) => request<Playbook>(`/playbooks/${id}`),
-	create: (data: { name: string; description: string; repo_url: string; branch: string; token?: string }) =>
+	create: (data: { name: string; description: string; repo_url: string; branch: string; token?: string; galaxy_server_url?: string; galaxy_token?: string }) =>
 		request<Playbook>('/playbooks', { method: 'POST', body: JSON.stringify(data) }),
-	update: (id: string, data: { name: string; description: string; repo_url: string; branch: string; token?: string }) =>
+	update: (id: string, data: { name: string; description: string; repo_url: string; branch: string; token?: string; galaxy_server_url?: string; galaxy_token?: string }) =>
 		request<Playbook>(`/playbooks/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
 	delete: (id: string) => request<void>(`/playbooks/${id}`, { method: 'DELETE' }),
 	listFiles: (id: string) => request<string[]>(`/playbooks/${id}/files`),
@@ -141,9 +162,9 @@ export const serverGroups = {
 	update: (id: string, data: { name: string; description: string }) =>
 		request<ServerGroup>(`/server-groups/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
 	delete: (id: string) => request<void>(`/server-groups/${id}`, { method: 'DELETE' }),
-	getMembers: (id: string) => request<Server[]>(`/server-groups/${id}/members`),
-	setMembers: (id: string, serverIds: string[]) =>
-		request<void>(`/server-groups/${id}/members`, { method: 'PUT', body: JSON.stringify({ server_ids: serverIds }) }),
+	getMembers: (id: string) => request<Host[]>(`/server-groups/${id}/members`),
+	setMembers: (id: string, hostIds: string[]) =>
+		request<void>(`/server-groups/${id}/members`, { method: 'PUT', body: JSON.stringify({ host_ids: hostIds }) }),
 };
 
 export const vaults = {
@@ -163,18 +184,76 @@ export const vaults = {
 };
 
 export const hosts = {
-	list: () => request<Host[]>('/hosts'),
+	/** With a selector, only the hosts whose labels match it; with facts
+	 * (key=value, e.g. os_family=Debian), only the hosts whose cached facts
+	 * match every one. */
+	list: (selector?: string, facts: string[] = []) => {
+		const params = new URLSearchParams();
+		if (selector) params.set('selector', selector);
+		for (const f of facts) params.append('fact', f);
+		const qs = params.toString();
+		return request<Host[]>(`/hosts${qs ? `?${qs}` : ''}`);
+	},
 	get: (id: string) => request<Host>(`/hosts/${id}`),
-	create: (data: { name: string; address: string; description: string; ssh_cert_id?: string | null; vars: Record<string, string> }) =>
+	create: (data: { name: string; address: string; description: string; ssh_cert_id?: string | null; vars: Record<string, unknown>; labels: Record<string, string> }) =>
 		request<Host>('/hosts', { method: 'POST', body: JSON.stringify(data) }),
-	update: (id: string, data: { name: string; address: string; description: string; ssh_cert_id?: string | null; vars: Record<string, string> }) =>
+	update: (id: string, data: { name: string; address: string; description: string; ssh_cert_id?: string | null; vars: Record<string, unknown>; labels: Record<string, string> }) =>
 		request<Host>(`/hosts/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
 	delete: (id: string) => request<void>(`/hosts/${id}`, { method: 'DELETE' }),
-	importFile: (file: File) => {
+	// The format (ini, yaml or json) is detected from the file unless given.
+	// dryRun reports what would change without writing; update overwrites the
+	// address and vars of hosts that already exist instead of skipping them.
+	importFile: (file: File, opts: { dryRun?: boolean; update?: boolean; format?: 'ini' | 'yaml' | 'json' } = {}) => {
 		const fd = new FormData();
 		fd.append('file', file);
-		return request<{ created: string[]; skipped: string[]; errors: string[] }>('/hosts/import', { method: 'POST', body: fd });
+		const params = new URLSearchParams();
+		if (opts.dryRun) params.set('dry_run', 'true');
+		if (opts.update) params.set('update', 'true');
+		if (opts.format) params.set('format', opts.format);
+		const qs = params.toString();
+		return request<HostImportResult>(`/hosts/import${qs ? `?${qs}` : ''}`, { method: 'POST', body: fd });
 	},
+	// Inventory file of every host and inventory group; SSH certs become
+	// ansible_ssh_private_key_file placeholders under keyDir (default ~/.ssh).
+	export: (format: 'ini' | 'yaml' | 'json', keyDir?: string) =>
+		requestBlob(`/hosts/export?format=${format}${keyDir ? `&key_dir=${encodeURIComponent(keyDir)}` : ''}`),
+	facts: (id: string) => request<HostFacts>(`/hosts/${id}/facts`),
+	// Runs the setup module against the host from a job runner.
+	gatherFacts: (id: string, serverId: string) =>
+		request<HostFacts>(`/hosts/${id}/facts/gather`, { method: 'POST', body: JSON.stringify({ server_id: serverId }) }),
+	// Runs Ansible's ping module against the host from a job runner and
+	// returns the host with its new last check.
+	ping: (id: string, serverId: string) =>
+		request<Host>(`/hosts/${id}/ping`, { method: 'POST', body: JSON.stringify({ server_id: serverId }) }),
+	// Pings hostIds, or the hosts matching selector, or every host.
+	pingMany: (serverId: string, opts: { hostIds?: string[]; selector?: string } = {}) =>
+		request<HostPingResult>('/hosts/ping', {
+			method: 'POST',
+			body: JSON.stringify({ server_id: serverId, host_ids: opts.hostIds, selector: opts.selector }),
+		}),
+};
+
+export const inventoryGroups = {
+	list: () => request<InventoryGroup[]>('/inventory-groups'),
+	get: (id: string) => request<InventoryGroup>(`/inventory-groups/${id}`),
+	create: (data: { name: string; description: string; vars: Record<string, unknown>; host_ids: string[]; child_ids: string[] }) =>
+		request<InventoryGroup>('/inventory-groups', { method: 'POST', body: JSON.stringify(data) }),
+	update: (id: string, data: { name: string; description: string; vars: Record<string, unknown>; host_ids: string[]; child_ids: string[] }) =>
+		request<InventoryGroup>(`/inventory-groups/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+	delete: (id: string) => request<void>(`/inventory-groups/${id}`, { method: 'DELETE' }),
+};
+
+export const inventorySources = {
+	list: () => request<InventorySource[]>('/inventory-sources'),
+	get: (id: string) => request<InventorySource>(`/inventory-sources/${id}`),
+	create: (data: InventorySourceWrite) =>
+		request<InventorySource>('/inventory-sources', { method: 'POST', body: JSON.stringify(data) }),
+	update: (id: string, data: InventorySourceWrite) =>
+		request<InventorySource>(`/inventory-sources/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+	delete: (id: string) => request<void>(`/inventory-sources/${id}`, { method: 'DELETE' }),
+	sync: (id: string) => request<InventorySync>(`/inventory-sources/${id}/sync`, { method: 'POST' }),
+	syncs: (id: string, limit?: number) =>
+		request<InventorySync[]>(`/inventory-sources/${id}/syncs${limit ? `?limit=${limit}` : ''}`),
 };
 
 export const sshCerts = {
@@ -208,6 +287,9 @@ export const settings = {
 	getGitHub: () => request<GitHubSettings>('/settings/github'),
 	updateGitHub: (data: GitHubSettings) =>
 		request<GitHubSettings>('/settings/github', { method: 'PUT', body: JSON.stringify(data) }),
+	getHostPing: () => request<HostPingSettings>('/settings/host-ping'),
+	updateHostPing: (data: HostPingSettings) =>
+		request<HostPingSettings>('/settings/host-ping', { method: 'PUT', body: JSON.stringify(data) }),
 };
 
 export const ee = {
@@ -234,7 +316,7 @@ export const audit = {
 
 export const runs = {
 	/** Returns a page of runs plus the total count across all pages. */
-	list: (params?: { limit?: number; offset?: number }) => {
+	list: (params?: { limit?: number; offset?: number; status?: string; changed?: boolean; failed?: boolean; unreachable?: boolean }) => {
 		const qs = params
 			? '?' +
 				new URLSearchParams(
@@ -248,10 +330,25 @@ export const runs = {
 		return requestPaged<Run[]>(`/runs${qs}`);
 	},
 	get: (id: string) => request<Run>(`/runs/${id}`),
-	create: (formId: string, variables: Record<string, unknown>) =>
+	create: (formId: string, variables: Record<string, unknown>, options: Partial<RunOptions> = {}) =>
 		request<{ run_id: string; status: string }>('/runs', {
 			method: 'POST',
-			body: JSON.stringify({ form_id: formId, variables }),
+			body: JSON.stringify({ form_id: formId, variables, options }),
 		}),
 	cancel: (id: string) => request<void>(`/runs/${id}/cancel`, { method: 'POST' }),
+	/** Starts the run again with its stored variables, target and options; failedHostsOnly limits it to the hosts that failed. */
+	relaunch: (id: string, failedHostsOnly = false) =>
+		request<{ run_id: string; parent_run_id: string; status: string }>(`/runs/${id}/relaunch`, { method: 'POST', body: JSON.stringify({ failed_hosts_only: failedHostsOnly }) }),
+	events: (id: string) => request<RunPlay[]>(`/runs/${id}/events`),
+	hosts: (id: string) => request<RunHostSummary[]>(`/runs/${id}/hosts`),
+};
+
+export const queue = {
+	get: () => request<QueueStatus>('/queue'),
+};
+
+export const batches = {
+	get: (id: string) => request<Batch>(`/batches/${id}`),
+	approve: (id: string) => request<void>(`/batches/${id}/approve`, { method: 'POST' }),
+	cancel: (id: string) => request<void>(`/batches/${id}/cancel`, { method: 'POST' }),
 };
@@ -1,6 +1,6 @@
 export type Role = 'admin' | 'editor' | 'viewer';
 export type FieldType = 'text' | 'number' | 'bool' | 'select';
-export type RunStatus = 'pending' | 'running' | 'success' | 'failed';
+export type RunStatus = 'pending' | 'running' | 'success' | 'failed' | 'cancelled' | 'timed_out';
 
 export interface User {
 	id: string;
@@ -19,9 +19,25 @@ export interface Server {
 	ssh_private_key?: string;
 	pre_command: string;
 	execution_environment: string;
+	runner_type: 'ssh' | 'kubernetes' | 'local';
+	host_key: string;
+	host_key_fingerprint: string;
+	host_ca_keys: string;
+	jump_server_id: string | null;
+	max_concurrent_runs: number; // 0 = no limit
 	created_at: string;
 }
 
+export interface ServerTestResult {
+	success: boolean;
+	message: string;
+	host_key_fingerprint?: string;
+	// Set when a host key in the chain is not trusted, so it can be pinned.
+	offered_server_id?: string;
+	offered_host_key?: string;
+	offered_fingerprint?: string;
+}
+
 export interface Playbook {
 	id: string;
 	name: string;
@@ -29,6 +45,8 @@ export interface Playbook {
 	repo_url: string;
 	branch: string;
 	token?: string;
+	galaxy_server_url: string;
+	has_galaxy_token: boolean;
 	created_at: string;
 }
 
@@ -69,10 +87,43 @@ export interface Host {
 	address: string;
 	description: string;
 	ssh_cert_id?: string | null;
-	vars: Record<string, string>;
+	vars: Record<string, unknown>; // any JSON value; playbooks see it with its type
+	labels: Record<string, string>; // matched by form host selectors, e.g. env=prod
+	source_id: string | null; // inventory source that last synced the host
+	last_check_status: '' | 'ok' | 'unreachable' | 'failed'; // last ping from a job runner; '' if never checked
+	last_check_at: string | null;
+	last_check_error: string; // Ansible's message when the last check was not ok
 	created_at: string;
 }
 
+export interface HostPingResult {
+	ok: number;
+	unreachable: number;
+	failed: number;
+	hosts: Host[]; // the pinged hosts with their new last check
+}
+
+export interface HostImportResult {
+	format: 'ini' | 'yaml' | 'json';
+	dry_run: boolean;
+	created: string[];
+	updated: string[];
+	skipped: string[];
+	errors: string[];
+	groups_created: string[];
+	groups_updated: string[];
+}
+
+// The ansible_facts last seen for a host: from a run's setup or
+// gather_facts task, or gathered on demand through a job runner.
+export interface HostFacts {
+	host_id: string;
+	facts: Record<string, unknown>;
+	source: 'run' | 'gather';
+	run_id: string | null; // run the facts came from, for source run
+	gathered_at: string;
+}
+
 export interface SSHCert {
 	id: string;
 	name: string;
@@ -81,6 +132,63 @@ export interface SSHCert {
 	created_at: string;
 }
 
+export interface InventoryGroup {
+	id: string;
+	name: string; // Ansible group name
+	description: string;
+	vars: Record<string, unknown>; // any JSON value, like host vars; written as [name:vars]
+	host_ids: string[];
+	child_ids: string[]; // written as [name:children]
+	created_at: string;
+}
+
+export type InventorySourceType = 'git' | 'runner' | 'http';
+
+export interface InventorySource {
+	id: string;
+	name: string;
+	description: string;
+	type: InventorySourceType;
+	playbook_id: string | null; // git: playbook source holding the inventory file
+	path: string; // git: file in the repo; runner: inventory passed to ansible-inventory -i
+	server_id: string | null; // runner
+	url: string; // http: returns ansible-inventory --list JSON
+	has_token: boolean; // http bearer token; never returned
+	schedule_cron: string;
+	delete_missing: boolean;
+	last_sync_at: string | null;
+	last_sync_status: '' | 'running' | 'success' | 'failed';
+	next_sync_at: string | null;
+	created_at: string;
+}
+
+export interface InventorySourceWrite {
+	name: string;
+	description: string;
+	type: InventorySourceType;
+	playbook_id: string;
+	path: string;
+	server_id: string;
+	url: string;
+	token?: string; // omitted or empty keeps the stored token
+	schedule_cron: string;
+	delete_missing: boolean;
+}
+
+export interface InventorySync {
+	id: string;
+	source_id: string;
+	trigger: 'manual' | 'schedule';
+	status: 'running' | 'success' | 'failed';
+	created: string[]; // host names
+	updated: string[];
+	deleted: string[];
+	skipped: string[];
+	message: string;
+	started_at: string;
+	finished_at: string | null;
+}
+
 export interface ServerGroup {
 	id: string;
 	name: string;
@@ -88,6 +196,57 @@ export interface ServerGroup {
 	created_at: string;
 }
 
+export interface RunOptions {
+	check: boolean;
+	diff: boolean;
+	limit: string;
+	tags: string;
+	skip_tags: string;
+	start_at_task: string;
+	forks: number;
+	verbosity: number;
+}
+
+export type RunOptionName = keyof RunOptions;
+
+/** How a server-group form's member runs are rolled out in batches. */
+export interface Rollout {
+	batch_size: string; // count ("5") or percentage ("25%"); '' = all at once
+	pause_seconds: number;
+	max_fail_percent: number | null; // null = never stop
+	require_approval: boolean;
+}
+
+export type BatchStatus = 'running' | 'paused' | 'awaiting_approval' | 'cancelling' | 'success' | 'failed' | 'stopped' | 'cancelled';
+
+export interface BatchMember {
+	run_id: string;
+	host: string;
+	phase: number;
+	status: RunStatus;
+	summary: RunSummary | null;
+	started_at: string | null;
+	finished_at: string | null;
+}
+
+/** The runs launched for a server-group form and their rollout progress. */
+export interface Batch {
+	id: string;
+	form_id: string | null;
+	form_name: string;
+	rollout: Rollout;
+	status: BatchStatus;
+	phase: number; // rollout batch running or last finished
+	phases: number;
+	message: string;
+	next_phase_at: string | null;
+	members: BatchMember[];
+	counts: Record<RunStatus, number>; // member runs per status
+	duration_seconds: number; // from launch to finish, or until now
+	created_at: string;
+	finished_at: string | null;
+}
+
 export interface Form {
 	id: string;
 	name: string;
@@ -97,11 +256,21 @@ export interface Form {
 	server_id?: string | null;
 	host_id?: string | null;
 	server_group_id?: string | null;
+	inventory_group_id?: string | null;
+	host_selector: string; // label selector, e.g. env=prod,role in (web,api),!canary
 	vault_id?: string | null;
 	is_quick_action: boolean;
 	image_name: string;
 	schedule_cron: string;
 	schedule_enabled: boolean;
+	schedule_check_mode: boolean;
+	schedule_diff_mode: boolean;
+	run_options: RunOptions;
+	overridable_options: RunOptionName[];
+	max_concurrent_runs: number; // 0 = no limit
+	max_runtime_minutes: number; // 0 = no limit
+	rollout: Rollout; // server-group targets only
+	group_run_mode: 'per_member' | 'single'; // server-group targets: one run per host, or one run for all
 	webhook_token: string;
 	notify_webhook: string;
 	notify_email: string;
@@ -121,10 +290,109 @@ export interface Run {
 	status: RunStatus;
 	output: string;
 	batch_id?: string | null;
+	batch_phase?: number; // rollout batch within batch_id, from 1
+	check_mode: boolean;
+	diff_mode: boolean;
+	options: RunOptions;
+	cancelled_by: string | null;
+	parent_run_id: string | null; // set on relaunches
+	summary: RunSummary | null; // null until a PLAY RECAP is recorded
+	queue_position?: number; // pending runs waiting in the queue
+	recap?: RunHostStats[]; // only on GET /runs/:id
 	started_at: string | null;
 	finished_at: string | null;
 }
 
+export interface QueueEntry {
+	run_id: string;
+	form_id: string | null;
+	form_name: string;
+	server_id: string;
+	batch_id: string | null;
+	status: RunStatus;
+	attempts: number;
+	position?: number; // queued runs only
+	blocked?: string; // why a queued run can't start yet
+	enqueued_at: string;
+	claimed_at: string | null;
+}
+
+export interface QueueStatus {
+	workers: number;
+	active: QueueEntry[];
+	queued: QueueEntry[];
+}
+
+export interface RunHostStats {
+	host: string;
+	ok: number;
+	changed: number;
+	unreachable: number;
+	failed: number;
+	skipped: number;
+	rescued: number;
+	ignored: number;
+}
+
+export interface RunSummary extends Omit<RunHostStats, 'host'> {
+	hosts: number;
+	changed_hosts: number;
+	failed_hosts: number;
+	unreachable_hosts: number;
+}
+
+export type RunResultStatus = 'ok' | 'changed' | 'failed' | 'skipped' | 'unreachable';
+
+export interface RunHostResult {
+	id: string;
+	run_id: string;
+	task_id: string;
+	host: string;
+	status: RunResultStatus;
+	action: string;
+	msg: string;
+	duration: number | null; // seconds
+	ignore_errors: boolean;
+	created_at: string;
+}
+
+export interface RunTask {
+	id: string;
+	run_id: string;
+	play_id: string;
+	name: string;
+	action: string;
+	handler: boolean;
+	started_at: string;
+	results: RunHostResult[];
+}
+
+export interface RunPlay {
+	id: string;
+	run_id: string;
+	name: string;
+	started_at: string;
+	tasks: RunTask[];
+}
+
+export interface RunHostFailure {
+	task_id: string;
+	task: string;
+	status: RunResultStatus;
+	msg: string;
+	ignore_errors: boolean;
+}
+
+export interface RunHostSummary {
+	host: string;
+	ok: number;
+	changed: number;
+	failed: number;
+	skipped: number;
+	unreachable: number;
+	failures: RunHostFailure[];
+}
+
 export interface AuditLog {
 	id: string;
 	user_id: string;
@@ -141,6 +409,14 @@ export interface AppSettings {
 	app_url: string;
 }
 
+// Scheduled fleet ping: every host pinged from one job runner on a cron
+// schedule; an empty cron disables it.
+export interface HostPingSettings {
+	host_ping_cron: string;
+	host_ping_server_id: string;
+	next_run_at?: string | null;
+}
+
 export interface GitHubSettings {
 	github_token: string;
 	github_repo: string;  // e.g. "owner/repo"